@@ -33,6 +33,9 @@ func ProcessStream(sc *core.ServerConfiguration, namespace string, outputs []str
 	var wg sync.WaitGroup
 
 	numDigesters := int(sc.ProcessorConcurrency)
+	if numDigesters < 1 {
+		numDigesters = 1
+	}
 	wg.Add(numDigesters)
 
 	for i := 0; i < numDigesters; i++ {
@@ -120,12 +123,9 @@ func digester(sc *core.ServerConfiguration, namespace string, outputs []string,
 		glog.Infof("About to process image: %s", item.Hash)
 		localOriginalPath := sc.Adapters.Paths.LocalOriginalPath(namespace, item.Hash)
 
-		for _, filename := range itemOutputs {
-			err := processImage(sc, namespace, item.Hash, localOriginalPath, filename)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
+		err = processImages(sc, namespace, item.Hash, localOriginalPath, imageDetails, itemOutputs)
+		if err != nil {
+			log.Println(err)
 		}
 
 		select {
@@ -221,46 +221,42 @@ func uploadOriginal(sc *core.ServerConfiguration, namespace string, item *Item,
 	return nil
 }
 
-func processImage(sc *core.ServerConfiguration, namespace string, hash string, localOriginalPath string, filename string) error {
-	ic, err := parser.NameToConfiguration(sc, filename)
-	if err != nil {
-		return fmt.Errorf("Error parsing name: %v\n", err)
-	}
-
-	ic.Namespace = namespace
-	ic.ID = hash
-
-	// process image
-	pchan := &processor.ProcessorChannels{
-		ImageProcessed: make(chan *core.ImageConfiguration),
-		Skipped:        make(chan string),
-	}
+// processImages creates the outputs of an image and uploads them, the original is decoded once when the
+// processor allows it. Outputs with an invalid name are skipped
+func processImages(sc *core.ServerConfiguration, namespace string, hash string, localOriginalPath string, imageDetails *info.ImageProperties, filenames []string) error {
+	var processors []*processor.Processor
+	for _, filename := range filenames {
+		ic, err := parser.NameToConfiguration(sc, namespace, filename)
+		if err != nil {
+			log.Printf("Error parsing name: %v\n", err)
+			continue
+		}
 
-	localPath := sc.Adapters.Paths.LocalImagePath(namespace, hash, filename)
+		ic.Namespace = namespace
+		ic.ID = hash
 
-	p := processor.Processor{
-		Source:             localOriginalPath,
-		Destination:        localPath,
-		ImageConfiguration: ic,
-		Channels:           pchan,
+		processors = append(processors, &processor.Processor{
+			Source:             localOriginalPath,
+			Destination:        sc.Adapters.Paths.LocalImagePath(namespace, hash, filename),
+			ImageConfiguration: ic,
+			ImageDetails:       imageDetails,
+			Backend:            sc.Processor,
+		})
 	}
 
-	err = p.CreateImage()
-
+	err := processor.CreateImages(processors)
 	if err != nil {
 		return err
 	}
 
-	select {
-	case <-pchan.ImageProcessed:
-		uploader := uploader.DefaultUploader(sc)
-		remoteResizedPath := sc.Adapters.Paths.RemoteImagePath(namespace, hash, filename)
-		err = uploader.Upload(localPath, remoteResizedPath, ic.ToContentType())
+	uploader := uploader.DefaultUploader(sc)
+	for _, p := range processors {
+		ic := p.ImageConfiguration
+		remoteResizedPath := sc.Adapters.Paths.RemoteImagePath(namespace, hash, ic.Filename)
+		err = uploader.Upload(p.Destination, remoteResizedPath, ic.ToContentType())
 		if err != nil {
 			log.Println(err)
 		}
-	case path := <-pchan.Skipped:
-		glog.Infof("Skipped processing (batch) %s", path)
 	}
 
 	return nil
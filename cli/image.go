@@ -161,7 +161,7 @@ func (i *Image) ToHash() string {
 // Takes a filename, sends Image metadata through a processor to generate that new file
 // Once complete, pushes a LocalImage onto channel c
 func (i *Image) ProcessOutput(sc *core.ServerConfiguration, namespace string, filename string) error {
-	ic, err := parser.NameToConfiguration(sc, filename)
+	ic, err := parser.NameToConfiguration(sc, namespace, filename)
 	if err != nil {
 		return fmt.Errorf("Error parsing name: %v\n", err)
 	}
@@ -181,6 +181,7 @@ func (i *Image) ProcessOutput(sc *core.ServerConfiguration, namespace string, fi
 		Destination:        localPath,
 		ImageConfiguration: ic,
 		Channels:           pchan,
+		Backend:            sc.Processor,
 	}
 
 	err = p.CreateImage()
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	cliprocessor "github.com/image-server/image-server/cli"
+	"github.com/image-server/image-server/core"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -95,8 +96,9 @@ func init() {
 
 	// HTTP Server settings
 	cmdCli.Flags().StringVar(&config.port, "port", "7000", "Specifies the server port.")
-	cmdCli.Flags().StringVar(&config.extensions, "extensions", "jpg,gif,webp", "Whitelisted extensions (separated by commas)")
+	cmdCli.Flags().StringVar(&config.extensions, "extensions", "jpg,gif,webp,avif,jxl", "Whitelisted extensions (separated by commas)")
 	cmdCli.Flags().StringVar(&config.localBasePath, "local_base_path", "public", "Directory where the images will be saved")
+	cmdCli.Flags().StringVar(&config.namespacesConfig, "namespaces_config", "", "YAML file with presets, outputs, extensions, quality and dimensions of each namespace")
 
 	// Uploader paths
 	cmdCli.Flags().StringVar(&config.remoteBaseURL, "remote_base_url", "", "Source domain for images")
@@ -119,12 +121,21 @@ func init() {
 	cmdCli.Flags().StringVar(&config.sdcIdentity, "sdc_identity", "", "Example: $HOME/.ssh/id_rsa")
 
 	// Default image settings
-	cmdCli.Flags().IntVar(&config.maximumWidth, "maximum_width", 1000, "Maximum image width")
+	cmdCli.Flags().IntVar(&config.maximumWidth, "maximum_width", 10000, "Maximum image width, 0 disables the limit")
+	cmdCli.Flags().IntVar(&config.maximumHeight, "maximum_height", 10000, "Maximum image height, 0 disables the limit")
+	cmdCli.Flags().IntVar(&config.maximumPixels, "maximum_pixels", 50000000, "Maximum pixels (width x height) of processed images, 0 disables the limit")
+	cmdCli.Flags().IntVar(&config.maximumSourcePixels, "maximum_source_pixels", 100000000, "Maximum pixels of original images, larger images are rejected. 0 disables the limit")
 	cmdCli.Flags().IntVar(&config.defaultQuality, "default_quality", 75, "Default image compression quality")
+	cmdCli.Flags().Float64Var(&config.autoQualitySSIM, "auto_quality_ssim", 0.98, "Minimum SSIM of outputs with an automatic quality (qauto)")
 
 	// Settings
 	cmdCli.Flags().IntVar(&config.uploaderConcurrency, "uploader_concurrency", 10, "Uploader concurrency")
-	cmdCli.Flags().IntVar(&config.processorConcurrency, "processor_concurrency", 4, "Processor concurrency")
+	cmdCli.Flags().IntVar(&config.processorConcurrency, "processor_concurrency", 4, "Maximum number of images processed at the same time, 0 disables the limit")
+	cmdCli.Flags().StringVar(&config.processor, "processor", core.ProcessorImageMagick, "Processor backend: imagemagick (the first installed of magick, convert and gm), imagemagick7, imagemagick6, graphicsmagick, or go to process JPEG, PNG and GIF outputs in Go")
+	cmdCli.Flags().IntVar(&config.processorTimeout, "processor_timeout", 60, "Seconds a conversion runs before it is killed, 0 disables the limit")
+	cmdCli.Flags().IntVar(&config.processorMemory, "processor_memory_limit", 0, "Megabytes of the pixel cache a conversion keeps in memory, its data segment is limited to twice this. 0 uses the processor default")
+	cmdCli.Flags().IntVar(&config.processorDisk, "processor_disk_limit", 100, "Megabytes of the pixel cache a conversion keeps on disk, and of the files it writes. 0 disables the limit")
+	cmdCli.Flags().IntVar(&config.processorThreads, "processor_threads", 0, "Threads used by a conversion, 0 uses the processor default")
 	cmdCli.Flags().IntVar(&config.httpTimeout, "http_timeout", 5, "HTTP request timeout in seconds")
 	cmdCli.Flags().IntVar(&config.gomaxprocs, "gomaxprocs", 0, "It will use the default when set to 0")
 
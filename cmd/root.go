@@ -13,15 +13,19 @@ import (
 	"github.com/image-server/image-server/logger/prometheus"
 	"github.com/image-server/image-server/logger/statsd"
 	"github.com/image-server/image-server/paths"
+	"github.com/image-server/image-server/processor"
+	adapter "github.com/image-server/image-server/processor/cli"
+	"github.com/image-server/image-server/processor/limits"
 	"github.com/image-server/image-server/uploader"
 	"github.com/spf13/cobra"
 )
 
 // configT collects all the global state of the logging setup.
 type configT struct {
-	port          string
-	extensions    string
-	localBasePath string
+	port             string
+	extensions       string
+	localBasePath    string
+	namespacesConfig string
 
 	remoteBaseURL  string
 	remoteBasePath string
@@ -43,13 +47,24 @@ type configT struct {
 	mantaKeyID  string
 	sdcIdentity string
 
-	maximumWidth   int
-	defaultQuality int
+	maximumWidth        int
+	maximumHeight       int
+	maximumPixels       int
+	maximumSourcePixels int
+	defaultQuality      int
+	autoQualitySSIM     float64
 
 	uploaderConcurrency  int
 	processorConcurrency int
+	processorQueue       int
+	processorQueueTime   int
+	processorTimeout     int
+	processorMemory      int
+	processorDisk        int
+	processorThreads     int
 	httpTimeout          int
 	gomaxprocs           int
+	processor            string
 
 	enableStatsd bool
 	statsdHost   string
@@ -79,6 +94,28 @@ func Execute() {
 
 func serverConfiguration() (*core.ServerConfiguration, error) {
 	sc := serverConfigurationFromConfig()
+	adapter.Probe()
+	if sc.Processor != core.ProcessorGo {
+		if err := adapter.Select(sc.Processor); err != nil {
+			return nil, err
+		}
+	}
+	processor.SetScheduler(processor.NewScheduler(sc.ProcessorConcurrency, sc.ProcessorQueue, sc.ProcessorQueueTimeout))
+	limits.Processes = limits.Limits{
+		Timeout: sc.ProcessorTimeout,
+		Memory:  sc.ProcessorMemoryLimit,
+		Disk:    sc.ProcessorDiskLimit,
+		Threads: sc.ProcessorThreads,
+	}
+
+	if config.namespacesConfig != "" {
+		namespaces, err := core.LoadNamespaceConfigurations(config.namespacesConfig)
+		if err != nil {
+			return nil, err
+		}
+		sc.Namespaces = namespaces
+	}
+
 	if config.enableStatsd {
 		statsd.Enable(config.statsdHost, config.statsdPort, config.statsdPrefix)
 	}
@@ -130,11 +167,14 @@ func serverConfigurationFromConfig() *core.ServerConfiguration {
 
 	return &core.ServerConfiguration{
 		AllowedExtensions: allowedExtensions,
-		LocalBasePath:         config.localBasePath,
+		LocalBasePath:     config.localBasePath,
 
-		MaximumWidth:   config.maximumWidth,
-		RemoteBasePath: config.remoteBasePath,
-		RemoteBaseURL:  config.remoteBaseURL,
+		MaximumWidth:        config.maximumWidth,
+		MaximumHeight:       config.maximumHeight,
+		MaximumPixels:       config.maximumPixels,
+		MaximumSourcePixels: config.maximumSourcePixels,
+		RemoteBasePath:      config.remoteBasePath,
+		RemoteBaseURL:       config.remoteBaseURL,
 
 		UploaderType: uploader,
 		MaxFileAge:   maxFileAge,
@@ -153,8 +193,18 @@ func serverConfigurationFromConfig() *core.ServerConfiguration {
 
 		Outputs:             config.outputs,
 		DefaultQuality:      uint(config.defaultQuality),
+		AutoQualitySSIM:     config.autoQualitySSIM,
 		UploaderConcurrency: uint(config.uploaderConcurrency),
 		HTTPTimeout:         httpTimeout,
+		Processor:           config.processor,
+
+		ProcessorConcurrency:  uint(config.processorConcurrency),
+		ProcessorQueue:        uint(config.processorQueue),
+		ProcessorQueueTimeout: time.Duration(config.processorQueueTime) * time.Second,
+		ProcessorTimeout:      time.Duration(config.processorTimeout) * time.Second,
+		ProcessorMemoryLimit:  config.processorMemory,
+		ProcessorDiskLimit:    config.processorDisk,
+		ProcessorThreads:      config.processorThreads,
 	}
 }
 
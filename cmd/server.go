@@ -10,6 +10,7 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/file_garbage_collector"
 	"github.com/image-server/image-server/server"
 )
@@ -52,8 +53,9 @@ func init() {
 
 	// HTTP Server settings
 	serverCmd.Flags().StringVar(&config.port, "port", "7000", "Specifies the server port.")
-	serverCmd.Flags().StringVar(&config.extensions, "extensions", "jpg,gif,webp", "Whitelisted extensions (separated by commas)")
+	serverCmd.Flags().StringVar(&config.extensions, "extensions", "jpg,gif,webp,avif,jxl", "Whitelisted extensions (separated by commas)")
 	serverCmd.Flags().StringVar(&config.localBasePath, "local_base_path", "public", "Directory where the images will be saved")
+	serverCmd.Flags().StringVar(&config.namespacesConfig, "namespaces_config", "", "YAML file with presets, outputs, extensions, quality and dimensions of each namespace")
 
 	// Uploader paths
 	serverCmd.Flags().StringVar(&config.remoteBaseURL, "remote_base_url", "", "Source domain for images")
@@ -76,12 +78,23 @@ func init() {
 	serverCmd.Flags().StringVar(&config.sdcIdentity, "sdc_identity", "", "Example: $HOME/.ssh/id_rsa")
 
 	// Default image settings
-	serverCmd.Flags().IntVar(&config.maximumWidth, "maximum_width", 1000, "Maximum image width")
+	serverCmd.Flags().IntVar(&config.maximumWidth, "maximum_width", 10000, "Maximum image width, 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.maximumHeight, "maximum_height", 10000, "Maximum image height, 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.maximumPixels, "maximum_pixels", 50000000, "Maximum pixels (width x height) of processed images, 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.maximumSourcePixels, "maximum_source_pixels", 100000000, "Maximum pixels of original images, larger images are rejected. 0 disables the limit")
 	serverCmd.Flags().IntVar(&config.defaultQuality, "default_quality", 75, "Default image compression quality")
+	serverCmd.Flags().Float64Var(&config.autoQualitySSIM, "auto_quality_ssim", 0.98, "Minimum SSIM of outputs with an automatic quality (qauto)")
 
 	// Settings
 	serverCmd.Flags().IntVar(&config.uploaderConcurrency, "uploader_concurrency", 10, "Uploader concurrency")
-	serverCmd.Flags().IntVar(&config.processorConcurrency, "processor_concurrency", 4, "Processor concurrency")
+	serverCmd.Flags().IntVar(&config.processorConcurrency, "processor_concurrency", 4, "Maximum number of images processed at the same time, 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.processorQueue, "processor_queue", 100, "Maximum number of images waiting to be processed, more images are rejected with 429. 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.processorQueueTime, "processor_queue_timeout", 10, "Seconds an image waits to be processed before it is rejected with 429, 0 disables the limit")
+	serverCmd.Flags().StringVar(&config.processor, "processor", core.ProcessorImageMagick, "Processor backend: imagemagick (the first installed of magick, convert and gm), imagemagick7, imagemagick6, graphicsmagick, or go to process JPEG, PNG and GIF outputs in Go")
+	serverCmd.Flags().IntVar(&config.processorTimeout, "processor_timeout", 60, "Seconds a conversion runs before it is killed, 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.processorMemory, "processor_memory_limit", 0, "Megabytes of the pixel cache a conversion keeps in memory, its data segment is limited to twice this. 0 uses the processor default")
+	serverCmd.Flags().IntVar(&config.processorDisk, "processor_disk_limit", 100, "Megabytes of the pixel cache a conversion keeps on disk, and of the files it writes. 0 disables the limit")
+	serverCmd.Flags().IntVar(&config.processorThreads, "processor_threads", 0, "Threads used by a conversion, 0 uses the processor default")
 	serverCmd.Flags().IntVar(&config.httpTimeout, "http_timeout", 5, "HTTP request timeout in seconds")
 	serverCmd.Flags().IntVar(&config.gomaxprocs, "gomaxprocs", 0, "It will use the default when set to 0")
 
@@ -0,0 +1,83 @@
+package core
+
+import "fmt"
+
+// Chroma subsampling of JPEG outputs
+const (
+	Sampling444 = "444"
+	Sampling422 = "422"
+	Sampling420 = "420"
+)
+
+// EncoderConfiguration struct
+// Encoder options of an output, zero values use the encoder defaults
+type EncoderConfiguration struct {
+	// Progressive encodes interlaced JPEG and PNG outputs
+	Progressive bool `yaml:"progressive"`
+	// Sampling is the chroma subsampling of JPEG outputs: 444, 422 or 420
+	Sampling string `yaml:"sampling"`
+	// Lossless encodes lossless WebP outputs
+	Lossless bool `yaml:"lossless"`
+	// NearLossless encodes lossless WebP outputs with lossy preprocessing, from 1 (smallest) to 99
+	NearLossless int `yaml:"near_lossless"`
+	// Method of the WebP encoder, from 1 (fastest) to 6 (smallest)
+	Method int `yaml:"method"`
+	// Compression level of PNG outputs, from 1 (fastest) to 9 (smallest)
+	Compression int `yaml:"compression"`
+	// Colors quantizes PNG and GIF outputs to a palette of 2 to 256 colors
+	Colors int `yaml:"colors"`
+}
+
+// Validate returns an error when an option is out of range or not supported by the format
+func (ec *EncoderConfiguration) Validate(format string) error {
+	jpeg := format == "jpg" || format == "jpeg"
+
+	switch {
+	case ec.Progressive && !jpeg && format != "png":
+		return fmt.Errorf("progressive is only supported by jpg and png outputs")
+	case ec.Sampling != "" && !jpeg:
+		return fmt.Errorf("sampling is only supported by jpg outputs")
+	case ec.Sampling != "" && ec.Sampling != Sampling444 && ec.Sampling != Sampling422 && ec.Sampling != Sampling420:
+		return fmt.Errorf("unknown sampling %s", ec.Sampling)
+	case (ec.Lossless || ec.NearLossless != 0 || ec.Method != 0) && format != "webp":
+		return fmt.Errorf("lossless, near lossless and method are only supported by webp outputs")
+	case ec.NearLossless < 0 || ec.NearLossless > 99:
+		return fmt.Errorf("near lossless must be between 1 and 99")
+	case ec.Method < 0 || ec.Method > 6:
+		return fmt.Errorf("method must be between 1 and 6")
+	case ec.Compression != 0 && format != "png":
+		return fmt.Errorf("compression is only supported by png outputs")
+	case ec.Compression < 0 || ec.Compression > 9:
+		return fmt.Errorf("compression must be between 1 and 9")
+	case ec.Colors != 0 && format != "png" && format != "gif":
+		return fmt.Errorf("colors is only supported by png and gif outputs")
+	case ec.Colors != 0 && (ec.Colors < 2 || ec.Colors > 256):
+		return fmt.Errorf("colors must be between 2 and 256")
+	}
+	return nil
+}
+
+// Merge sets the options missing from the configuration to the options of defaults
+func (ec *EncoderConfiguration) Merge(defaults *EncoderConfiguration) {
+	if defaults == nil {
+		return
+	}
+
+	ec.Progressive = ec.Progressive || defaults.Progressive
+	ec.Lossless = ec.Lossless || defaults.Lossless
+	if ec.Sampling == "" {
+		ec.Sampling = defaults.Sampling
+	}
+	if ec.NearLossless == 0 {
+		ec.NearLossless = defaults.NearLossless
+	}
+	if ec.Method == 0 {
+		ec.Method = defaults.Method
+	}
+	if ec.Compression == 0 {
+		ec.Compression = defaults.Compression
+	}
+	if ec.Colors == 0 {
+		ec.Colors = defaults.Colors
+	}
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// Codes of the errors, returned in the JSON body of error responses
+const (
+	// ErrorSourceMissing: the original or the source URL doesn't exist (404)
+	ErrorSourceMissing = "source_missing"
+	// ErrorSourceUnreachable: the original or the source URL can't be downloaded (502)
+	ErrorSourceUnreachable = "source_unreachable"
+	// ErrorCorruptInput: the image can't be decoded or processed (422)
+	ErrorCorruptInput = "corrupt_input"
+	// ErrorUnsupportedFormat: the format of the image or of the output is not supported (415)
+	ErrorUnsupportedFormat = "unsupported_format"
+	// ErrorPolicyViolation: the request violates the limits of the server or of a namespace (400)
+	ErrorPolicyViolation = "policy_violation"
+	// ErrorProcessorTimeout: the processor was killed after the time limit (504)
+	ErrorProcessorTimeout = "processor_timeout"
+	// ErrorStorageFailure: the images can't be stored or listed (503)
+	ErrorStorageFailure = "storage_failure"
+	// ErrorOverloaded: the server is processing too many images, retry after RetryAfter (429)
+	ErrorOverloaded = "overloaded"
+	// ErrorInternal is the code of errors without a code
+	ErrorInternal = "internal_error"
+)
+
+// Error is an error with a code telling clients what went wrong, handlers respond with the status of the code
+type Error struct {
+	Code    string
+	Message string
+	// Detail is the error output of the processor
+	Detail string
+	// RetryAfter is the time clients wait before they retry an overloaded server
+	RetryAfter time.Duration
+}
+
+// NewError returns an Error with a code and a formatted message
+func NewError(code string, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the code of err, ErrorInternal when err is not an Error
+func ErrorCode(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return ErrorInternal
+}
+
+// NewPolicyError returns an Error when a request violates the limits of the server or of a namespace
+func NewPolicyError(format string, a ...interface{}) error {
+	return NewError(ErrorPolicyViolation, format, a...)
+}
+
+// IsPolicyError returns true when err is a policy violation
+func IsPolicyError(err error) bool {
+	return ErrorCode(err) == ErrorPolicyViolation
+}
+
+// NewOverloadedError returns an Error when an image is not processed because the server is overloaded
+func NewOverloadedError(retryAfter time.Duration) error {
+	e := NewError(ErrorOverloaded, "the server is overloaded, retry in %s", retryAfter)
+	e.RetryAfter = retryAfter
+	return e
+}
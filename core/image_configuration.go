@@ -1,10 +1,94 @@
 package core
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"regexp"
+
 	"github.com/golang/glog"
 	"github.com/image-server/image-server/mime"
 )
 
+// Resize modes used when both width and height are provided
+const (
+	// ResizeModeCrop fills the requested dimensions and crops the overflow (default)
+	ResizeModeCrop = "crop"
+	// ResizeModeFit resizes the image to fit within the requested dimensions
+	ResizeModeFit = "fit"
+	// ResizeModePad fits the image and pads the remaining area with the background color
+	ResizeModePad = "pad"
+)
+
+// Gravity values used to anchor crops and padding
+const (
+	GravityCenter    = "center"
+	GravityNorth     = "north"
+	GravitySouth     = "south"
+	GravityEast      = "east"
+	GravityWest      = "west"
+	GravityNorthEast = "northeast"
+	GravityNorthWest = "northwest"
+	GravitySouthEast = "southeast"
+	GravitySouthWest = "southwest"
+	// GravitySmart anchors crops on the area of the image with the most detail
+	GravitySmart = "smart"
+)
+
+// Metadata policies of the outputs
+const (
+	// MetadataStrip removes every profile and comment
+	MetadataStrip = "strip"
+	// MetadataCredits keeps the IPTC and XMP profiles, and the artist and copyright of the EXIF profile
+	MetadataCredits = "credits"
+	// MetadataNoGPS keeps the metadata without the location of the image
+	MetadataNoGPS = "nogps"
+)
+
+// ValidMetadataPolicy returns true for the known metadata policies
+func ValidMetadataPolicy(policy string) bool {
+	return policy == MetadataStrip || policy == MetadataCredits || policy == MetadataNoGPS
+}
+
+// Quality modes of the outputs
+const (
+	// QualityFixed uses the quality of the output, or the default quality
+	QualityFixed = "fixed"
+	// QualityAuto uses the lowest quality that keeps the SSIM of the output above a threshold
+	QualityAuto = "auto"
+	// QualitySize uses the highest quality of an output smaller than a target size
+	QualitySize = "size"
+)
+
+// BackgroundTransparent keeps the transparency of the original, and pads outputs with transparent pixels
+const BackgroundTransparent = "transparent"
+
+// defaultBackground fills transparent and padded areas when the output has no background
+const defaultBackground = "rgba(255,255,255,1)"
+
+var reBackground = regexp.MustCompile(`^(transparent|[0-9a-f]{6})$`)
+
+// ValidBackground returns true for "transparent" and hexadecimal RGB colors, i.e. "000000"
+func ValidBackground(background string) bool {
+	return reBackground.MatchString(background)
+}
+
+// alphaFormats are the output formats that keep transparency
+var alphaFormats = map[string]bool{"png": true, "gif": true, "webp": true, "avif": true, "jxl": true}
+
+// Region is a rectangle of the original, in pixels
+type Region struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Within returns true when the region is inside of an image
+func (r *Region) Within(width int, height int) bool {
+	return r.Width <= width && r.Height <= height && r.X <= width-r.Width && r.Y <= height-r.Height
+}
+
 // ImageConfiguration struct
 // Properties used to generate new image
 type ImageConfiguration struct {
@@ -17,6 +101,42 @@ type ImageConfiguration struct {
 	Source    string
 	Quality   uint
 	Namespace string
+	Mode      string
+	Gravity   string
+	NoUpscale bool
+	// AutoQuality searches the lowest quality with an SSIM of at least SSIM, the quality is set once the output is processed
+	AutoQuality bool
+	SSIM        float64
+	// TargetSize searches the highest quality of an output smaller than TargetSize kilobytes
+	TargetSize int
+	// Speed of the AVIF encoder, 0 uses the encoder default
+	Speed int
+	// Effort of the JPEG XL encoder, 0 uses the encoder default
+	Effort int
+	// Options of the JPEG, WebP and PNG encoders
+	EncoderConfiguration
+	// Still uses the first frame of animated images
+	Still bool
+	// KeepProfile embeds a compact sRGB profile in the output
+	KeepProfile bool
+	// Metadata is the metadata policy of the output
+	Metadata string
+	// Background is "transparent" or a hexadecimal RGB color. When it is empty outputs that keep
+	// transparency are transparent, and white is used for the rest
+	Background string
+	// Region of the original that is cropped before any other operation
+	Region *Region
+	// Filters, in the order they are applied. Trim, Rotate, Flip and Flop are applied before resizing
+	Trim       bool
+	Rotate     int
+	Flip       bool
+	Flop       bool
+	Gray       bool
+	Brightness int
+	Contrast   int
+	Blur       int
+	Sharpen    int
+	Circle     bool
 }
 
 // ToContentType returns the content type based on the image format
@@ -29,3 +149,156 @@ func (ic *ImageConfiguration) ToContentType() string {
 
 	return contentType
 }
+
+// ResizeMode returns the resize mode, defaults to crop
+func (ic *ImageConfiguration) ResizeMode() string {
+	if ic.Mode == "" {
+		return ResizeModeCrop
+	}
+	return ic.Mode
+}
+
+// ResizeGravity returns the gravity used to anchor the image, defaults to center
+func (ic *ImageConfiguration) ResizeGravity() string {
+	if ic.Gravity == "" {
+		return GravityCenter
+	}
+	return ic.Gravity
+}
+
+// Transposed returns true when the rotation swaps the width and height of the original
+func (ic *ImageConfiguration) Transposed() bool {
+	return ic.Rotate == 90 || ic.Rotate == 270
+}
+
+// SourceDimensions returns the dimensions of an original once its region is cropped and it is rotated
+func (ic *ImageConfiguration) SourceDimensions(width int, height int) (int, int) {
+	if ic.Region != nil {
+		width, height = ic.Region.Width, ic.Region.Height
+	}
+	if ic.Transposed() {
+		width, height = height, width
+	}
+	return width, height
+}
+
+// Scale returns the factor the source (the original once its region is cropped and it is rotated) is resized by
+func (ic *ImageConfiguration) Scale(width int, height int) float64 {
+	cols, rows := ic.SourceDimensions(width, height)
+	if ic.Width == 0 || cols == 0 || rows == 0 {
+		return 1
+	}
+
+	scale := float64(ic.Width) / float64(cols)
+	if ic.Height > 0 {
+		h := float64(ic.Height) / float64(rows)
+		if ic.ResizeMode() == ResizeModeCrop {
+			scale = math.Max(scale, h)
+		} else {
+			scale = math.Min(scale, h)
+		}
+	}
+	if ic.NoUpscale && scale > 1 {
+		return 1
+	}
+	return scale
+}
+
+// OutputPoint moves a point of the original (fractions of its width and height) into the region,
+// and rotates and mirrors it with the output
+func (ic *ImageConfiguration) OutputPoint(x float64, y float64, width int, height int) (float64, float64) {
+	if r := ic.Region; r != nil && width > 0 && height > 0 {
+		x = (x*float64(width) - float64(r.X)) / float64(r.Width)
+		y = (y*float64(height) - float64(r.Y)) / float64(r.Height)
+	}
+
+	switch ic.Rotate {
+	case 90:
+		x, y = 1-y, x
+	case 180:
+		x, y = 1-x, 1-y
+	case 270:
+		x, y = y, 1-x
+	}
+	if ic.Flip {
+		y = 1 - y
+	}
+	if ic.Flop {
+		x = 1 - x
+	}
+	return x, y
+}
+
+// MetadataPolicy returns the metadata policy, metadata is stripped by default
+func (ic *ImageConfiguration) MetadataPolicy() string {
+	if ic.Metadata == "" {
+		return MetadataStrip
+	}
+	return ic.Metadata
+}
+
+// AlphaOutput returns true when the format of the output keeps transparency
+func (ic *ImageConfiguration) AlphaOutput() bool {
+	return alphaFormats[ic.Format]
+}
+
+// TransparentBackground returns true when transparent areas are kept in the output,
+// outputs that keep transparency are transparent unless they have a background color
+func (ic *ImageConfiguration) TransparentBackground() bool {
+	return ic.AlphaOutput() && (ic.Background == "" || ic.Background == BackgroundTransparent)
+}
+
+// BackgroundColor returns the ImageMagick color of the background.
+// Outputs without transparency use white for a transparent background
+func (ic *ImageConfiguration) BackgroundColor() string {
+	switch {
+	case ic.TransparentBackground():
+		return "none"
+	case ic.Background == "" || ic.Background == BackgroundTransparent:
+		return defaultBackground
+	}
+	return "#" + ic.Background
+}
+
+// QualityMode returns how the quality of the output is chosen
+func (ic *ImageConfiguration) QualityMode() string {
+	switch {
+	case ic.AutoQuality:
+		return QualityAuto
+	case ic.TargetSize > 0:
+		return QualitySize
+	}
+	return QualityFixed
+}
+
+// QualityExtension is the extension of the files storing the quality selected for outputs without a fixed quality
+const QualityExtension = ".quality"
+
+// QualityFile returns the file storing the quality selected for the output at path
+func QualityFile(path string) string {
+	return path + QualityExtension
+}
+
+type selectedQuality struct {
+	Quality uint `json:"quality"`
+}
+
+// SaveQuality stores the quality selected for the output at path, as {"quality": 72}
+func SaveQuality(path string, quality uint) error {
+	b, err := json.Marshal(selectedQuality{quality})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(QualityFile(path), b, 0644)
+}
+
+// LoadQuality returns the quality selected for the output at path
+func LoadQuality(path string) (uint, error) {
+	b, err := ioutil.ReadFile(QualityFile(path))
+	if err != nil {
+		return 0, err
+	}
+	var q selectedQuality
+	err = json.Unmarshal(b, &q)
+	return q.Quality, err
+}
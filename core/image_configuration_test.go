@@ -1,6 +1,10 @@
 package core_test
 
 import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/image-server/image-server/core"
@@ -30,3 +34,38 @@ func TestToContentTypeForGIF(t *testing.T) {
 
 	Equals(t, "image/gif", ic.ToContentType())
 }
+
+func TestBackgroundColor(t *testing.T) {
+	Equals(t, "none", (&core.ImageConfiguration{Format: "png"}).BackgroundColor())
+	Equals(t, "rgba(255,255,255,1)", (&core.ImageConfiguration{Format: "jpg"}).BackgroundColor())
+	Equals(t, "rgba(255,255,255,1)", (&core.ImageConfiguration{Format: "jpg", Background: core.BackgroundTransparent}).BackgroundColor())
+	Equals(t, "#00ff00", (&core.ImageConfiguration{Format: "webp", Background: "00ff00"}).BackgroundColor())
+}
+
+func TestQualityMode(t *testing.T) {
+	Equals(t, core.QualityFixed, (&core.ImageConfiguration{Quality: 80}).QualityMode())
+	Equals(t, core.QualityAuto, (&core.ImageConfiguration{AutoQuality: true}).QualityMode())
+	Equals(t, core.QualitySize, (&core.ImageConfiguration{TargetSize: 50}).QualityMode())
+}
+
+func TestRegionWithin(t *testing.T) {
+	Equals(t, true, (&core.Region{X: 10, Y: 20, Width: 90, Height: 80}).Within(100, 100))
+	Equals(t, false, (&core.Region{X: 11, Y: 20, Width: 90, Height: 80}).Within(100, 100))
+	Equals(t, false, (&core.Region{X: math.MaxInt64, Y: 0, Width: 1, Height: 1}).Within(100, 100))
+	Equals(t, false, (&core.Region{X: 0, Y: 0, Width: math.MaxInt64, Height: 1}).Within(100, 100))
+}
+
+func TestSaveQuality(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quality")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "w100-qauto.jpg")
+	Ok(t, core.SaveQuality(path, 72))
+	quality, err := core.LoadQuality(path)
+	Ok(t, err)
+	Equals(t, uint(72), quality)
+
+	_, err = core.LoadQuality(filepath.Join(dir, "w100.jpg"))
+	Assert(t, err != nil, "expected no quality for w100.jpg")
+}
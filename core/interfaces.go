@@ -19,6 +19,7 @@ type Logger interface {
 	ImageProcessed(ic *ImageConfiguration)
 	ImageAlreadyProcessed(ic *ImageConfiguration)
 	ImageProcessedWithErrors(ic *ImageConfiguration)
+	QualitySelected(ic *ImageConfiguration)
 	AllImagesAlreadyProcessed(namespace string, hash string, sourceURL string)
 	SourceDownloaded()
 	OriginalDownloaded(source string, destination string)
@@ -58,4 +59,5 @@ type Uploader interface {
 	CreateDirectory(string) error
 	Upload(string, string, string) error
 	ListDirectory(string) ([]string, error)
+	Delete(string) error
 }
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultNamespace is the name of the configuration used by namespaces missing from the namespaces file
+const DefaultNamespace = "default"
+
+// NamespaceConfiguration struct
+// Rules applied to the images of a namespace, loaded from the namespaces configuration file
+type NamespaceConfiguration struct {
+	// Presets are named outputs, i.e. "thumb.jpg" is processed as "x150-q80.jpg"
+	Presets map[string]string `yaml:"presets"`
+	// Outputs generated when an image is posted without outputs
+	Outputs []string `yaml:"outputs"`
+	// AllowedExtensions replaces the extensions allowed by the server
+	AllowedExtensions []string `yaml:"extensions"`
+	// Quality is the default quality for each format, i.e. jpg: 80
+	Quality map[string]uint `yaml:"quality"`
+	// Dimensions allowed in outputs, i.e. x150, 300x200, w600 or full_size. Every dimension is allowed when empty
+	Dimensions []string `yaml:"dimensions"`
+	// SigningKeys enables signed URLs. URLs are signed with the first key, and any key is accepted
+	SigningKeys []string `yaml:"signing_keys"`
+	// Metadata is the metadata policy of the outputs: strip (default), credits or nogps
+	Metadata string `yaml:"metadata"`
+	// Background of the outputs: transparent or a hexadecimal RGB color, i.e. 000000. White is used when empty
+	Background string `yaml:"background"`
+	// Encoders are the default encoder options of each format, i.e. jpg: {progressive: true}
+	Encoders map[string]*EncoderConfiguration `yaml:"encoders"`
+	// Derive resizes outputs from an existing larger output instead of the original
+	Derive *DeriveConfiguration `yaml:"derive"`
+}
+
+// Defaults of the derive configuration
+const (
+	DefaultDeriveScale   = 2.0
+	DefaultDeriveQuality = 90
+)
+
+// DeriveConfiguration struct
+// Bounds the quality lost by outputs resized from an existing output, zero values use the defaults
+type DeriveConfiguration struct {
+	// Scale is the minimum ratio between the existing output and the output resized from it
+	Scale float64 `yaml:"scale"`
+	// Quality is the minimum quality of existing JPEG and lossy WebP outputs
+	Quality uint `yaml:"quality"`
+}
+
+// Validate returns an error when the scale or the quality is out of range
+func (dc *DeriveConfiguration) Validate() error {
+	switch {
+	case dc.Scale < 0 || dc.Scale > 0 && dc.Scale < 1:
+		return fmt.Errorf("scale must be at least 1")
+	case dc.Quality > 100:
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	return nil
+}
+
+// MinimumScale returns the minimum ratio between the existing output and the output resized from it
+func (dc *DeriveConfiguration) MinimumScale() float64 {
+	if dc.Scale == 0 {
+		return DefaultDeriveScale
+	}
+	return dc.Scale
+}
+
+// MinimumQuality returns the minimum quality of existing lossy outputs
+func (dc *DeriveConfiguration) MinimumQuality() uint {
+	if dc.Quality == 0 {
+		return DefaultDeriveQuality
+	}
+	return dc.Quality
+}
+
+// LoadNamespaceConfigurations reads the namespaces configuration file.
+// The file is a YAML map with a configuration for each namespace
+func LoadNamespaceConfigurations(path string) (map[string]*NamespaceConfiguration, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaces := make(map[string]*NamespaceConfiguration)
+	err = yaml.UnmarshalStrict(b, &namespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, nc := range namespaces {
+		if nc != nil && nc.Metadata != "" && !ValidMetadataPolicy(nc.Metadata) {
+			return nil, fmt.Errorf("unknown metadata policy %s in namespace %s", nc.Metadata, name)
+		}
+		if nc != nil && nc.Background != "" && !ValidBackground(nc.Background) {
+			return nil, fmt.Errorf("invalid background %s in namespace %s", nc.Background, name)
+		}
+		if nc != nil && nc.Derive != nil {
+			if err := nc.Derive.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid derive configuration in namespace %s: %s", name, err)
+			}
+		}
+		for format, ec := range nc.encoders() {
+			if err := ec.Validate(format); err != nil {
+				return nil, fmt.Errorf("invalid %s encoder in namespace %s: %s", format, name, err)
+			}
+		}
+	}
+	return namespaces, nil
+}
+
+// Preset returns the output represented by a preset name, and false when the name is not a preset
+func (nc *NamespaceConfiguration) Preset(filename string) (string, bool) {
+	if nc == nil {
+		return "", false
+	}
+	output, ok := nc.Presets[filename]
+	return output, ok
+}
+
+// RequiresSignature returns true when the URLs of processed images must be signed
+func (nc *NamespaceConfiguration) RequiresSignature() bool {
+	return nc != nil && len(nc.SigningKeys) > 0
+}
+
+// AllowsDimension returns true when the dimension is in the allowlist, or when there is no allowlist
+func (nc *NamespaceConfiguration) AllowsDimension(dimension string) bool {
+	if nc == nil || len(nc.Dimensions) == 0 {
+		return true
+	}
+	for _, d := range nc.Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
+
+func (nc *NamespaceConfiguration) encoders() map[string]*EncoderConfiguration {
+	if nc == nil {
+		return nil
+	}
+	return nc.Encoders
+}
@@ -0,0 +1,115 @@
+package core_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestLoadNamespaceConfigurations(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{DefaultQuality: 75, AllowedExtensions: []string{"jpg"}, Namespaces: namespaces}
+
+	Equals(t, []string{"thumb.jpg", "thumb.webp", "x300.jpg"}, sc.OutputsFor("avatars"))
+	Equals(t, uint(85), sc.DefaultQualityFor("avatars", "jpg"))
+	Equals(t, uint(75), sc.DefaultQualityFor("avatars", "gif"))
+	Equals(t, []string{"jpg", "webp", "png"}, sc.AllowedExtensionsFor("avatars"))
+
+	output, ok := sc.Namespace("avatars").Preset("thumb.webp")
+	Equals(t, true, ok)
+	Equals(t, "x150-q70.webp", output)
+}
+
+func TestDefaultNamespaceConfiguration(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{DefaultQuality: 75, AllowedExtensions: []string{"jpg"}, Namespaces: namespaces}
+
+	Equals(t, []string{"jpg", "webp"}, sc.AllowedExtensionsFor("products"))
+	Equals(t, []string(nil), sc.OutputsFor("products"))
+	Equals(t, true, sc.Namespace("products").AllowsDimension("300x200"))
+}
+
+func TestNamespaceMetadataPolicy(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{Namespaces: namespaces}
+
+	Equals(t, core.MetadataCredits, sc.MetadataFor("editorial"))
+	Equals(t, core.MetadataNoGPS, sc.MetadataFor("products"))
+	Equals(t, core.MetadataStrip, (&core.ServerConfiguration{}).MetadataFor("products"))
+}
+
+func TestNamespaceBackground(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{Namespaces: namespaces}
+
+	Equals(t, core.BackgroundTransparent, sc.BackgroundFor("logos"))
+	Equals(t, "", sc.BackgroundFor("products"))
+}
+
+func TestNamespaceEncoders(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{Namespaces: namespaces}
+
+	Equals(t, &core.EncoderConfiguration{Progressive: true, Sampling: core.Sampling420}, sc.EncoderFor("heroes", "jpg"))
+	Equals(t, 6, sc.EncoderFor("heroes", "webp").Method)
+	Assert(t, sc.EncoderFor("heroes", "png") == nil, "expected png to use the encoder defaults")
+}
+
+func TestInvalidNamespaceEncoder(t *testing.T) {
+	path := "../test/config/invalid-encoder.yml"
+	Ok(t, ioutil.WriteFile(path, []byte("icons:\n  encoders:\n    jpg:\n      lossless: true\n"), 0644))
+	defer os.Remove(path)
+
+	_, err := core.LoadNamespaceConfigurations(path)
+	Assert(t, err != nil, "expected an error for a lossless jpg encoder")
+}
+
+func TestUnknownMetadataPolicy(t *testing.T) {
+	path := "../test/config/unknown-metadata.yml"
+	Ok(t, ioutil.WriteFile(path, []byte("editorial:\n  metadata: everything\n"), 0644))
+	defer os.Remove(path)
+
+	_, err := core.LoadNamespaceConfigurations(path)
+	Assert(t, err != nil, "expected an error for an unknown metadata policy")
+}
+
+func TestServerWithoutNamespaces(t *testing.T) {
+	sc := &core.ServerConfiguration{DefaultQuality: 75, AllowedExtensions: []string{"jpg"}}
+
+	Equals(t, []string{"jpg"}, sc.AllowedExtensionsFor("products"))
+	Equals(t, uint(75), sc.DefaultQualityFor("products", "jpg"))
+}
+
+func TestNamespaceDerive(t *testing.T) {
+	namespaces, err := core.LoadNamespaceConfigurations("../test/config/namespaces.yml")
+	Ok(t, err)
+
+	sc := &core.ServerConfiguration{Namespaces: namespaces}
+
+	dc := sc.DeriveFor("catalog")
+	Equals(t, 3.0, dc.MinimumScale())
+	Equals(t, uint(core.DefaultDeriveQuality), dc.MinimumQuality())
+	Assert(t, sc.DeriveFor("products") == nil, "expected outputs of products to be resized from the original")
+}
+
+func TestInvalidNamespaceDerive(t *testing.T) {
+	path := "../test/config/invalid-derive.yml"
+	Ok(t, ioutil.WriteFile(path, []byte("catalog:\n  derive:\n    scale: 0.5\n"), 0644))
+	defer os.Remove(path)
+
+	_, err := core.LoadNamespaceConfigurations(path)
+	Assert(t, err != nil, "expected an error for a derive scale below 1")
+}
@@ -5,16 +5,41 @@ import (
 	"time"
 )
 
+// Processor backends
+const (
+	// ProcessorImageMagick processes every image with the first installed of ImageMagick 7, ImageMagick 6 and GraphicsMagick
+	ProcessorImageMagick = "imagemagick"
+	// ProcessorImageMagick7, ProcessorImageMagick6 and ProcessorGraphicsMagick select a command line processor
+	ProcessorImageMagick7   = "imagemagick7"
+	ProcessorImageMagick6   = "imagemagick6"
+	ProcessorGraphicsMagick = "graphicsmagick"
+	// ProcessorGo processes images in Go, and uses the first installed command line processor for the outputs it doesn't support
+	ProcessorGo = "go"
+)
+
 // ServerConfiguration struct
 type ServerConfiguration struct {
 	AllowedExtensions []string
 	MaximumWidth          int
+	MaximumHeight         int
+	MaximumPixels         int
+	MaximumSourcePixels   int
 	LocalBasePath         string
 	RemoteBasePath        string
 	RemoteBaseURL         string
 	DefaultQuality        uint
+	AutoQualitySSIM       float64
 	UploaderConcurrency   uint
 	ProcessorConcurrency  uint
+	// ProcessorQueue bounds the outputs waiting for a worker, and ProcessorQueueTimeout the time they wait
+	ProcessorQueue        uint
+	ProcessorQueueTimeout time.Duration
+	// ProcessorTimeout kills conversions running longer, the memory and disk limits are in megabytes
+	ProcessorTimeout      time.Duration
+	ProcessorMemoryLimit  int
+	ProcessorDiskLimit    int
+	ProcessorThreads      int
+	Processor             string
 	HTTPTimeout           time.Duration
 	Adapters              *Adapters
 	Outputs               string
@@ -29,6 +54,105 @@ type ServerConfiguration struct {
 	UploaderType          string
 	CleanUpTicker         *time.Ticker
 	MaxFileAge            time.Duration
+	Namespaces            map[string]*NamespaceConfiguration
+}
+
+// Namespace returns the configuration of a namespace. Namespaces missing from the
+// configuration use the default namespace configuration, or nil when there is none
+func (sc *ServerConfiguration) Namespace(namespace string) *NamespaceConfiguration {
+	if nc, ok := sc.Namespaces[namespace]; ok {
+		return nc
+	}
+	return sc.Namespaces[DefaultNamespace]
+}
+
+// CheckDimensions returns a PolicyError when the dimensions exceed the maximum width, height or pixels.
+// A dimension of 0 is not checked, limits of 0 are disabled
+func (sc *ServerConfiguration) CheckDimensions(width int, height int) error {
+	if sc.MaximumWidth > 0 && width > sc.MaximumWidth {
+		return NewPolicyError("width %d exceeds the maximum width of %d", width, sc.MaximumWidth)
+	}
+	if sc.MaximumHeight > 0 && height > sc.MaximumHeight {
+		return NewPolicyError("height %d exceeds the maximum height of %d", height, sc.MaximumHeight)
+	}
+	if exceedsPixels(width, height, sc.MaximumPixels) {
+		return NewPolicyError("%dx%d exceeds the maximum of %d pixels", width, height, sc.MaximumPixels)
+	}
+	return nil
+}
+
+// CheckSourceDimensions returns a PolicyError when an original image exceeds the maximum source pixels
+func (sc *ServerConfiguration) CheckSourceDimensions(width int, height int) error {
+	if exceedsPixels(width, height, sc.MaximumSourcePixels) {
+		return NewPolicyError("source image %dx%d exceeds the maximum of %d pixels", width, height, sc.MaximumSourcePixels)
+	}
+	return nil
+}
+
+// exceedsPixels returns true when width x height is larger than a maximum above 0, without overflowing
+func exceedsPixels(width int, height int, maximum int) bool {
+	if maximum <= 0 || width <= 0 || height <= 0 {
+		return false
+	}
+	return width > maximum/height
+}
+
+// AllowedExtensionsFor returns the extensions allowed in a namespace
+func (sc *ServerConfiguration) AllowedExtensionsFor(namespace string) []string {
+	if nc := sc.Namespace(namespace); nc != nil && len(nc.AllowedExtensions) > 0 {
+		return nc.AllowedExtensions
+	}
+	return sc.AllowedExtensions
+}
+
+// DefaultQualityFor returns the quality used when an output does not provide one
+func (sc *ServerConfiguration) DefaultQualityFor(namespace string, format string) uint {
+	if nc := sc.Namespace(namespace); nc != nil {
+		if quality, ok := nc.Quality[format]; ok && quality > 0 {
+			return quality
+		}
+	}
+	return sc.DefaultQuality
+}
+
+// MetadataFor returns the metadata policy of the outputs of a namespace
+func (sc *ServerConfiguration) MetadataFor(namespace string) string {
+	if nc := sc.Namespace(namespace); nc != nil && nc.Metadata != "" {
+		return nc.Metadata
+	}
+	return MetadataStrip
+}
+
+// BackgroundFor returns the background of the outputs of a namespace, empty when it is not configured
+func (sc *ServerConfiguration) BackgroundFor(namespace string) string {
+	if nc := sc.Namespace(namespace); nc != nil {
+		return nc.Background
+	}
+	return ""
+}
+
+// EncoderFor returns the default encoder options of a format in a namespace, nil when they are not configured
+func (sc *ServerConfiguration) EncoderFor(namespace string, format string) *EncoderConfiguration {
+	if nc := sc.Namespace(namespace); nc != nil {
+		return nc.Encoders[format]
+	}
+	return nil
+}
+
+// DeriveFor returns the derive configuration of a namespace, nil when outputs are always resized from the original
+func (sc *ServerConfiguration) DeriveFor(namespace string) *DeriveConfiguration {
+	if nc := sc.Namespace(namespace); nc != nil {
+		return nc.Derive
+	}
+	return nil
+}
+
+// OutputsFor returns the outputs generated when an image is posted without outputs
+func (sc *ServerConfiguration) OutputsFor(namespace string) []string {
+	if nc := sc.Namespace(namespace); nc != nil {
+		return nc.Outputs
+	}
+	return nil
 }
 
 func (sc *ServerConfiguration) UploaderIsAws() bool {
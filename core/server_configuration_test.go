@@ -0,0 +1,40 @@
+package core_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestCheckDimensions(t *testing.T) {
+	sc := &core.ServerConfiguration{MaximumWidth: 1000, MaximumHeight: 800, MaximumPixels: 500000}
+
+	Ok(t, sc.CheckDimensions(1000, 500))
+	Ok(t, sc.CheckDimensions(600, 0))
+	Equals(t, true, core.IsPolicyError(sc.CheckDimensions(1001, 100)))
+	Equals(t, true, core.IsPolicyError(sc.CheckDimensions(100, 801)))
+	Equals(t, true, core.IsPolicyError(sc.CheckDimensions(800, 800)))
+}
+
+func TestCheckDimensionsDoesNotOverflow(t *testing.T) {
+	sc := &core.ServerConfiguration{MaximumPixels: 500000}
+
+	Equals(t, true, core.IsPolicyError(sc.CheckDimensions(1<<32, 1<<32)))
+	Equals(t, true, core.IsPolicyError(sc.CheckDimensions(math.MaxInt64, 2)))
+}
+
+func TestCheckDimensionsWithoutLimits(t *testing.T) {
+	sc := &core.ServerConfiguration{}
+
+	Ok(t, sc.CheckDimensions(99999, 99999))
+	Ok(t, sc.CheckSourceDimensions(99999, 99999))
+}
+
+func TestCheckSourceDimensions(t *testing.T) {
+	sc := &core.ServerConfiguration{MaximumSourcePixels: 1000000}
+
+	Ok(t, sc.CheckSourceDimensions(1000, 1000))
+	Equals(t, true, core.IsPolicyError(sc.CheckSourceDimensions(1001, 1000)))
+}
@@ -0,0 +1,216 @@
+package exif
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// ErrInvalidExif is returned when the data is not a valid TIFF structure
+var ErrInvalidExif = errors.New("invalid exif")
+
+// EXIF tags
+const (
+	TagOrientation     = 0x0112
+	TagArtist          = 0x013b
+	TagCopyright       = 0x8298
+	tagExifIFD         = 0x8769
+	tagGPSIFD          = 0x8825
+	tagInteropIFD      = 0xa005
+	tagThumbnailOffset = 0x0201
+	tagThumbnailLength = 0x0202
+)
+
+// maximumDepth limits the nested IFDs, to protect against cycles
+const maximumDepth = 4
+
+// sizes of the TIFF field types, in bytes
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8, 13: 4}
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func parse(data []byte) (*tiff, error) {
+	if len(data) < 8 {
+		return nil, ErrInvalidExif
+	}
+
+	t := &tiff{data: data}
+	switch string(data[0:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, ErrInvalidExif
+	}
+	return t, nil
+}
+
+func (t *tiff) firstIFD() int {
+	return int(t.order.Uint32(t.data[4:8]))
+}
+
+// zero overwrites a range of the data, ranges outside of the data are ignored
+func (t *tiff) zero(offset int, length int) {
+	if offset < 0 || length < 0 || offset+length > len(t.data) {
+		return
+	}
+	for i := offset; i < offset+length; i++ {
+		t.data[i] = 0
+	}
+}
+
+// filter removes the entries of an IFD rejected by keep, and overwrites their values and nested IFDs.
+// It returns the offset of the next IFD
+func (t *tiff) filter(offset int, keep func(tag uint16) bool, depth int) (int, error) {
+	if depth > maximumDepth || offset < 8 || offset+2 > len(t.data) {
+		return 0, ErrInvalidExif
+	}
+
+	count := int(t.order.Uint16(t.data[offset : offset+2]))
+	entries := offset + 2
+	end := entries + 12*count
+	if end+4 > len(t.data) {
+		return 0, ErrInvalidExif
+	}
+	next := int(t.order.Uint32(t.data[end : end+4]))
+
+	kept := make([][]byte, 0, count)
+	thumbnailOffset, thumbnailLength := 0, 0
+	for i := 0; i < count; i++ {
+		entry := t.data[entries+12*i : entries+12*i+12]
+		tag := t.order.Uint16(entry[0:2])
+		if keep(tag) {
+			kept = append(kept, append([]byte{}, entry...))
+			continue
+		}
+
+		value := int(t.order.Uint32(entry[8:12]))
+		switch tag {
+		case tagExifIFD, tagGPSIFD, tagInteropIFD:
+			if _, err := t.filter(value, keepNone, depth+1); err != nil {
+				return 0, err
+			}
+		case tagThumbnailOffset:
+			thumbnailOffset = value
+		case tagThumbnailLength:
+			thumbnailLength = value
+		}
+
+		size := typeSizes[t.order.Uint16(entry[2:4])] * int(t.order.Uint32(entry[4:8]))
+		if size > 4 {
+			t.zero(value, size)
+		}
+	}
+	t.zero(thumbnailOffset, thumbnailLength)
+
+	// the kept entries are moved to the front, followed by the offset of the next IFD
+	t.zero(entries, end+4-entries)
+	t.order.PutUint16(t.data[offset:offset+2], uint16(len(kept)))
+	for i, entry := range kept {
+		copy(t.data[entries+12*i:], entry)
+	}
+	t.order.PutUint32(t.data[entries+12*len(kept):], uint32(next))
+
+	return next, nil
+}
+
+// unlink removes the IFD following the IFD at offset
+func (t *tiff) unlink(offset int) {
+	count := int(t.order.Uint16(t.data[offset : offset+2]))
+	t.order.PutUint32(t.data[offset+2+12*count:], 0)
+}
+
+func keepNone(tag uint16) bool {
+	return false
+}
+
+// RemoveGPS removes the GPS IFD of an EXIF profile (a TIFF structure), the data is modified in place
+func RemoveGPS(data []byte) error {
+	t, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = t.filter(t.firstIFD(), func(tag uint16) bool { return tag != tagGPSIFD }, 0)
+	return err
+}
+
+// KeepCredits removes every entry of an EXIF profile except the artist, the copyright and the orientation.
+// The thumbnail IFD is removed too, the data is modified in place
+func KeepCredits(data []byte) error {
+	t, err := parse(data)
+	if err != nil {
+		return err
+	}
+
+	first := t.firstIFD()
+	next, err := t.filter(first, func(tag uint16) bool {
+		return tag == TagArtist || tag == TagCopyright || tag == TagOrientation
+	}, 0)
+	if err != nil {
+		return err
+	}
+
+	if next != 0 {
+		if _, err := t.filter(next, keepNone, 0); err != nil {
+			return err
+		}
+		t.unlink(first)
+	}
+	return nil
+}
+
+// FilterJPEG applies filter to the EXIF profiles of a JPEG file
+func FilterJPEG(path string, filter func([]byte) error) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return ErrInvalidExif
+	}
+
+	filtered := false
+	for offset := 2; offset+4 <= len(data); {
+		if data[offset] != 0xff {
+			return ErrInvalidExif
+		}
+
+		marker := data[offset+1]
+		if marker == 0xda || marker == 0xd9 {
+			// start of scan or end of image
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return ErrInvalidExif
+		}
+
+		payload := data[offset+4 : end]
+		if marker == 0xe1 && len(payload) > 6 && string(payload[0:6]) == "Exif\x00\x00" {
+			if err := filter(payload[6:]); err != nil {
+				return err
+			}
+			filtered = true
+		}
+		offset = end
+	}
+
+	if !filtered {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, info.Mode())
+}
@@ -0,0 +1,102 @@
+package exif_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/image-server/image-server/exif"
+	. "github.com/image-server/image-server/test"
+)
+
+const (
+	tagMake        = 0x010f
+	tagGPSIFD      = 0x8825
+	tagGPSLatitude = 0x0002
+)
+
+var latitude = []byte{0, 0, 0, 41, 0, 0, 0, 1, 0, 0, 0, 23, 0, 0, 0, 1, 0, 0, 0, 42, 0, 0, 0, 1}
+
+// buildExif returns a big endian TIFF structure with the artist, the make and the location of a photo
+func buildExif() []byte {
+	b := make([]byte, 128)
+	copy(b, "MM\x00\x2a\x00\x00\x00\x08")
+
+	entry := func(at int, tag uint16, kind uint16, count uint32, value uint32) {
+		binary.BigEndian.PutUint16(b[at:], tag)
+		binary.BigEndian.PutUint16(b[at+2:], kind)
+		binary.BigEndian.PutUint32(b[at+4:], count)
+		binary.BigEndian.PutUint32(b[at+8:], value)
+	}
+
+	// IFD0 at 8 with 3 entries, next IFD at 46 is 0
+	binary.BigEndian.PutUint16(b[8:], 3)
+	entry(10, exif.TagArtist, 2, 9, 50)
+	entry(22, tagMake, 2, 7, 60)
+	entry(34, tagGPSIFD, 4, 1, 70)
+	copy(b[50:], "Jane Doe\x00")
+	copy(b[60:], "Camera\x00")
+
+	// GPS IFD at 70 with the latitude at 88
+	binary.BigEndian.PutUint16(b[70:], 1)
+	entry(72, tagGPSLatitude, 5, 3, 88)
+	copy(b[88:], latitude)
+
+	return b
+}
+
+func ifd0Tags(b []byte) []uint16 {
+	count := int(binary.BigEndian.Uint16(b[8:10]))
+	tags := []uint16{}
+	for i := 0; i < count; i++ {
+		tags = append(tags, binary.BigEndian.Uint16(b[10+12*i:]))
+	}
+	return tags
+}
+
+func TestRemoveGPS(t *testing.T) {
+	b := buildExif()
+	Ok(t, exif.RemoveGPS(b))
+
+	Equals(t, []uint16{exif.TagArtist, tagMake}, ifd0Tags(b))
+	Equals(t, false, bytes.Contains(b, latitude))
+	Equals(t, true, bytes.Contains(b, []byte("Camera")))
+}
+
+func TestKeepCredits(t *testing.T) {
+	b := buildExif()
+	Ok(t, exif.KeepCredits(b))
+
+	Equals(t, []uint16{exif.TagArtist}, ifd0Tags(b))
+	Equals(t, true, bytes.Contains(b, []byte("Jane Doe")))
+	Equals(t, false, bytes.Contains(b, []byte("Camera")))
+	Equals(t, false, bytes.Contains(b, latitude))
+}
+
+func TestInvalidExif(t *testing.T) {
+	Equals(t, exif.ErrInvalidExif, exif.RemoveGPS([]byte("not exif")))
+}
+
+func TestFilterJPEG(t *testing.T) {
+	original, err := ioutil.ReadFile("../test/images/a.jpg")
+	Ok(t, err)
+
+	payload := append([]byte("Exif\x00\x00"), buildExif()...)
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	data := append(append(append([]byte{0xff, 0xd8}, segment...), payload...), original[2:]...)
+
+	path := "../test/exif-filter.jpg"
+	Ok(t, ioutil.WriteFile(path, data, 0644))
+	defer os.Remove(path)
+
+	Ok(t, exif.FilterJPEG(path, exif.RemoveGPS))
+
+	filtered, err := ioutil.ReadFile(path)
+	Ok(t, err)
+	Equals(t, len(data), len(filtered))
+	Equals(t, false, bytes.Contains(filtered, latitude))
+	Equals(t, true, bytes.Contains(filtered, []byte("Jane Doe")))
+}
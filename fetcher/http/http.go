@@ -1,17 +1,18 @@
 package http
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/image-server/image-server/core"
 )
 
 type Fetcher struct{}
@@ -36,13 +37,17 @@ func (f *Fetcher) Fetch(url string, destination string) error {
 		resp, err := client.Get(url)
 
 		if err != nil {
-			return err
+			return requestError(err)
 		}
 		defer resp.Body.Close()
 		defer transport.CloseIdleConnections()
 
 		if resp.StatusCode != 200 {
-			return fmt.Errorf("Unable to download image: %s, status code: %d", url, resp.StatusCode)
+			code := core.ErrorSourceMissing
+			if resp.StatusCode >= 500 {
+				code = core.ErrorSourceUnreachable
+			}
+			return core.NewError(code, "Unable to download image: %s, status code: %d", url, resp.StatusCode)
 		}
 
 		glog.Infof("Downloaded from %s with code %d", url, resp.StatusCode)
@@ -67,7 +72,7 @@ func (f *Fetcher) Fetch(url string, destination string) error {
 
 		if fileInfo.Size() < 10 {
 			defer os.Remove(destination)
-			return errors.New("File is empty")
+			return core.NewError(core.ErrorCorruptInput, "File is empty")
 		}
 
 		glog.Infof("Took %s to download image: %s", time.Since(start), destination)
@@ -76,3 +81,14 @@ func (f *Fetcher) Fetch(url string, destination string) error {
 	}
 	return nil
 }
+
+// requestError returns a source unreachable error when the server of the URL can't be reached or doesn't respond,
+// and a source missing error when the URL is not valid
+func requestError(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		if _, ok := ue.Err.(net.Error); ok {
+			return core.NewError(core.ErrorSourceUnreachable, "%s", err)
+		}
+	}
+	return core.NewError(core.ErrorSourceMissing, "%s", err)
+}
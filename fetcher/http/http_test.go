@@ -7,6 +7,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/image-server/image-server/core"
 	httpFetcher "github.com/image-server/image-server/fetcher/http"
 
 	. "github.com/image-server/image-server/test"
@@ -61,3 +62,26 @@ func TestURLEscaping(t *testing.T) {
 
 	Ok(t, err)
 }
+
+func TestFetcherErrorCodes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	f := &httpFetcher.Fetcher{}
+
+	err := f.Fetch(ts.URL+"/missing.jpg", "missing.jpg")
+	Equals(t, core.ErrorSourceMissing, core.ErrorCode(err))
+
+	err = f.Fetch(ts.URL+"/error.jpg", "error.jpg")
+	Equals(t, core.ErrorSourceUnreachable, core.ErrorCode(err))
+
+	ts.Close()
+	err = f.Fetch(ts.URL+"/closed.jpg", "closed.jpg")
+	Equals(t, core.ErrorSourceUnreachable, core.ErrorCode(err))
+}
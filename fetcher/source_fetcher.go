@@ -13,11 +13,13 @@ import (
 // Used to download images from an external site
 type SourceFetcher struct {
 	Paths core.Paths
+	// ServerConfiguration provides the maximum source pixels, the limit is not checked when nil
+	ServerConfiguration *core.ServerConfiguration
 }
 
 // NewSourceFetcher initializes a SourceFetcher
 func NewSourceFetcher(paths core.Paths) *SourceFetcher {
-	return &SourceFetcher{paths}
+	return &SourceFetcher{Paths: paths}
 }
 
 // Fetch returns ImageDetails of downloaded file
@@ -49,20 +51,25 @@ func (f *SourceFetcher) StoreBinary(body io.ReadCloser, namespace string, conten
 		return nil, err
 	}
 
-	i := info.Info{Path: tmpOriginalPath}
-
-	md5, err := i.FileHash()
+	// The original is only stored when it is within the limits
+	i := info.Info{Path: tmpOriginalPath, ContentType: contentType}
+	imageDetails, err := i.ImageDetails()
+	if err == nil {
+		err = f.checkSourceDimensions(imageDetails)
+	}
 	if err != nil {
+		os.Remove(tmpOriginalPath)
 		return nil, err
 	}
 
-	destination := f.Paths.LocalOriginalPath(namespace, md5)
+	destination := f.Paths.LocalOriginalPath(namespace, imageDetails.Hash)
 	ensureDestinationDirectory(destination)
 	err = os.Rename(tmpOriginalPath, destination)
-	i.Path = destination
-	i.ContentType = contentType
+	if err != nil {
+		return nil, err
+	}
 
-	return i.ImageDetails()
+	return imageDetails, nil
 }
 
 // Even if simultaneous calls request the same image, only the first one will download
@@ -75,32 +82,36 @@ func (f *SourceFetcher) uniqueFetchSource(c chan FetchResult, url string, namesp
 		return
 	}
 
-	// file hash the image url
-	md5, err := info.Info{Path: tmpOriginalPath}.FileHash()
+	// generate image details, the original is only stored when it is within the limits
+	imageDetails, err := info.Info{Path: tmpOriginalPath}.ImageDetails()
+	if err == nil {
+		err = f.checkSourceDimensions(imageDetails)
+	}
 	if err != nil {
 		f.notifyDownloadSourceFailed(c, err)
 		return
 	}
 
 	// move file to destination
-	destination := f.Paths.LocalOriginalPath(namespace, md5)
+	destination := f.Paths.LocalOriginalPath(namespace, imageDetails.Hash)
 	err = f.copyImageFromTmp(tmpOriginalPath, destination)
 	if err != nil {
 		f.notifyDownloadSourceFailed(c, err)
 		return
 	}
 
-	// generate image details
-	imageDetails, err := info.Info{Path: destination}.ImageDetails()
-	if err != nil {
-		f.notifyDownloadSourceFailed(c, err)
-		return
-	}
-
 	c <- FetchResult{nil, imageDetails, downloaded}
 	close(c)
 }
 
+// checkSourceDimensions rejects decompression bombs, images too large to be processed
+func (f *SourceFetcher) checkSourceDimensions(imageDetails *info.ImageProperties) error {
+	if f.ServerConfiguration == nil {
+		return nil
+	}
+	return f.ServerConfiguration.CheckSourceDimensions(imageDetails.Width, imageDetails.Height)
+}
+
 func (f *SourceFetcher) copyImageFromTmp(tmpOriginalPath string, destination string) error {
 	// only copy image if does not exist
 	if _, err := os.Stat(destination); os.IsNotExist(err) {
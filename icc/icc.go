@@ -0,0 +1,198 @@
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrInvalidProfile is returned when the data is not an ICC profile
+var ErrInvalidProfile = errors.New("invalid icc profile")
+
+// Profile has the properties of an ICC profile used to pick a color conversion
+type Profile struct {
+	// ColorSpace of the data, i.e. "RGB", "CMYK" or "GRAY"
+	ColorSpace  string
+	Description string
+}
+
+// IsSRGB returns true for sRGB profiles, which don't need a conversion
+func (p *Profile) IsSRGB() bool {
+	return IsSRGB(p.Description)
+}
+
+// IsSRGB returns true when the profile description is one of an sRGB profile
+func IsSRGB(description string) bool {
+	return strings.Contains(strings.ToLower(description), "srgb")
+}
+
+// Parse reads the color space and the description of an ICC profile
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, ErrInvalidProfile
+	}
+
+	p := &Profile{ColorSpace: strings.TrimSpace(string(data[16:20]))}
+
+	count := int(binary.BigEndian.Uint32(data[128:132]))
+	for i := 0; i < count; i++ {
+		entry := 132 + 12*i
+		if entry+12 > len(data) {
+			return nil, ErrInvalidProfile
+		}
+		if string(data[entry:entry+4]) != "desc" {
+			continue
+		}
+
+		offset := int(binary.BigEndian.Uint32(data[entry+4 : entry+8]))
+		size := int(binary.BigEndian.Uint32(data[entry+8 : entry+12]))
+		if offset < 0 || size < 12 || offset+size > len(data) {
+			return nil, ErrInvalidProfile
+		}
+		p.Description = description(data[offset : offset+size])
+	}
+
+	return p, nil
+}
+
+// description reads a textDescriptionType (v2) or a multiLocalizedUnicodeType (v4) tag
+func description(tag []byte) string {
+	switch string(tag[0:4]) {
+	case "desc":
+		length := int(binary.BigEndian.Uint32(tag[8:12]))
+		if length > len(tag)-12 {
+			return ""
+		}
+		return strings.TrimRight(string(tag[12:12+length]), "\x00")
+	case "mluc":
+		if len(tag) < 28 {
+			return ""
+		}
+		length := int(binary.BigEndian.Uint32(tag[20:24]))
+		offset := int(binary.BigEndian.Uint32(tag[24:28]))
+		if offset+length > len(tag) {
+			return ""
+		}
+		text := make([]uint16, length/2)
+		for i := range text {
+			text[i] = binary.BigEndian.Uint16(tag[offset+2*i:])
+		}
+		return string(utf16.Decode(text))
+	}
+	return ""
+}
+
+// SRGBDescription is the description of the profile returned by SRGB
+const SRGBDescription = "sRGB compact"
+
+// SRGB returns a compact ICC v4 sRGB profile (matrix and parametric curves)
+func SRGB() []byte {
+	tags := []struct {
+		signature string
+		data      []byte
+	}{
+		{"desc", mluc(SRGBDescription)},
+		{"cprt", mluc("No copyright, use freely")},
+		{"wtpt", xyz(0.9642, 1.0, 0.8249)},
+		{"chad", sf32(
+			1.0478112, 0.0228866, -0.0501270,
+			0.0295424, 0.9904844, -0.0170491,
+			-0.0092345, 0.0150436, 0.7521316,
+		)},
+		{"rXYZ", xyz(0.4360747, 0.2225045, 0.0139322)},
+		{"gXYZ", xyz(0.3850649, 0.7168786, 0.0971045)},
+		{"bXYZ", xyz(0.1430804, 0.0606169, 0.7141733)},
+		// the sRGB transfer function, shared by the three channels
+		{"rTRC", para(2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045)},
+		{"gTRC", nil},
+		{"bTRC", nil},
+	}
+
+	table := &bytes.Buffer{}
+	data := &bytes.Buffer{}
+	dataOffset := 128 + 4 + 12*len(tags)
+	var shared [2]int
+
+	writeUint32(table, uint32(len(tags)))
+	for _, tag := range tags {
+		if tag.data != nil {
+			shared = [2]int{dataOffset + data.Len(), len(tag.data)}
+			data.Write(tag.data)
+			for data.Len()%4 != 0 {
+				data.WriteByte(0)
+			}
+		}
+		table.WriteString(tag.signature)
+		writeUint32(table, uint32(shared[0]))
+		writeUint32(table, uint32(shared[1]))
+	}
+
+	header := &bytes.Buffer{}
+	writeUint32(header, uint32(dataOffset+data.Len()))
+	writeUint32(header, 0)
+	writeUint32(header, 0x04200000)
+	header.WriteString("mntrRGB XYZ ")
+	for _, v := range []uint16{2020, 1, 1, 0, 0, 0} {
+		binary.Write(header, binary.BigEndian, v)
+	}
+	header.WriteString("acsp")
+	header.Write(make([]byte, 28))
+	header.Write(xyz(0.9642, 1.0, 0.8249)[8:])
+	header.Write(make([]byte, 128-header.Len()))
+
+	return append(append(header.Bytes(), table.Bytes()...), data.Bytes()...)
+}
+
+func writeUint32(b *bytes.Buffer, v uint32) {
+	binary.Write(b, binary.BigEndian, v)
+}
+
+func s15Fixed16(b *bytes.Buffer, values ...float64) {
+	for _, v := range values {
+		binary.Write(b, binary.BigEndian, int32(math.Round(v*65536)))
+	}
+}
+
+func mluc(text string) []byte {
+	b := &bytes.Buffer{}
+	encoded := utf16.Encode([]rune(text))
+	b.WriteString("mluc")
+	writeUint32(b, 0)
+	writeUint32(b, 1)
+	writeUint32(b, 12)
+	b.WriteString("enUS")
+	writeUint32(b, uint32(2*len(encoded)))
+	writeUint32(b, 28)
+	binary.Write(b, binary.BigEndian, encoded)
+	return b.Bytes()
+}
+
+func xyz(x, y, z float64) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("XYZ ")
+	writeUint32(b, 0)
+	s15Fixed16(b, x, y, z)
+	return b.Bytes()
+}
+
+func sf32(values ...float64) []byte {
+	b := &bytes.Buffer{}
+	b.WriteString("sf32")
+	writeUint32(b, 0)
+	s15Fixed16(b, values...)
+	return b.Bytes()
+}
+
+// para is a parametric curve of function type 3
+func para(g, a, b, c, d float64) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString("para")
+	writeUint32(buf, 0)
+	binary.Write(buf, binary.BigEndian, uint16(3))
+	binary.Write(buf, binary.BigEndian, uint16(0))
+	s15Fixed16(buf, g, a, b, c, d)
+	return buf.Bytes()
+}
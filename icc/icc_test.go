@@ -0,0 +1,46 @@
+package icc_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/image-server/image-server/icc"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestParseSRGB(t *testing.T) {
+	data := icc.SRGB()
+	Equals(t, uint32(len(data)), binary.BigEndian.Uint32(data[0:4]))
+	Equals(t, 0, len(data)%4)
+
+	p, err := icc.Parse(data)
+	Ok(t, err)
+	Equals(t, "RGB", p.ColorSpace)
+	Equals(t, icc.SRGBDescription, p.Description)
+	Equals(t, true, p.IsSRGB())
+}
+
+func TestParseVersion2Description(t *testing.T) {
+	description := "Display P3\x00"
+	data := make([]byte, 144)
+	copy(data[16:], "RGB ")
+	copy(data[36:], "acsp")
+	binary.BigEndian.PutUint32(data[128:], 1)
+	copy(data[132:], "desc")
+	binary.BigEndian.PutUint32(data[136:], 144)
+	binary.BigEndian.PutUint32(data[140:], uint32(12+len(description)))
+	tag := make([]byte, 12)
+	copy(tag, "desc")
+	binary.BigEndian.PutUint32(tag[8:], uint32(len(description)))
+	data = append(append(data, tag...), description...)
+
+	p, err := icc.Parse(data)
+	Ok(t, err)
+	Equals(t, "Display P3", p.Description)
+	Equals(t, false, p.IsSRGB())
+}
+
+func TestParseInvalidProfile(t *testing.T) {
+	_, err := icc.Parse([]byte("not a profile"))
+	Equals(t, icc.ErrInvalidProfile, err)
+}
@@ -0,0 +1,82 @@
+package info
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image/color"
+	"io"
+)
+
+// hasAlpha returns true when a SVG, GIF or WebP image has transparency. The color model
+// of the other formats is enough, but GIF and WebP decoders don't report their transparency
+func hasAlpha(r io.ReadSeeker, contentType string) (bool, error) {
+	switch contentType {
+	case "image/svg+xml":
+		return true, nil
+	case "image/gif":
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		return gifTransparency(bufio.NewReader(r))
+	case "image/webp":
+		return webpAlpha(r)
+	}
+	return false, nil
+}
+
+// alphaModel returns true for the color models returned by image.DecodeConfig with non-premultiplied alpha
+// and palettes with transparent colors. Decoders use the premultiplied models for opaque images too
+func alphaModel(model color.Model) bool {
+	switch model {
+	case color.NRGBAModel, color.NRGBA64Model, color.AlphaModel, color.Alpha16Model:
+		return true
+	}
+
+	if palette, ok := model.(color.Palette); ok {
+		for _, c := range palette {
+			if _, _, _, a := c.RGBA(); a < 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gifTransparency returns true when a graphic control extension has a transparent color
+func gifTransparency(r *bufio.Reader) (bool, error) {
+	transparent := false
+	_, err := gifControls(r, func(control []byte) {
+		transparent = transparent || control[1]&0x01 != 0
+	})
+	return transparent, err
+}
+
+// webpAlpha reads the alpha flag of the VP8X chunk, or the alpha hint of lossless images
+func webpAlpha(r io.ReadSeeker) (bool, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	header := make([]byte, 30)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	header = header[:n]
+	if n < 21 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return false, errUnknownHeader
+	}
+
+	switch string(header[12:16]) {
+	case "VP8X":
+		return header[20]&0x10 != 0, nil
+	case "VP8L":
+		if n < 25 {
+			return false, errUnknownHeader
+		}
+		// the signature is followed by the width and height (14 bits each) and the alpha hint
+		bits := binary.LittleEndian.Uint32(header[21:25])
+		return bits&(1<<28) != 0, nil
+	}
+	return false, nil
+}
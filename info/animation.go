@@ -0,0 +1,165 @@
+package info
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errInvalidAnimation = errors.New("invalid animation")
+
+// animation counts the frames of GIF and WebP images, and adds their delays in milliseconds.
+// Still images have one frame
+func animation(r io.ReadSeeker, contentType string) (frames int, duration int, err error) {
+	if contentType != "image/gif" && contentType != "image/webp" {
+		return 1, 0, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	if contentType == "image/gif" {
+		return gifAnimation(bufio.NewReader(r))
+	}
+	return webpAnimation(r)
+}
+
+// gifAnimation counts the image descriptors of a GIF, and adds the delays of its graphic control extensions
+func gifAnimation(r *bufio.Reader) (frames int, duration int, err error) {
+	frames, err = gifControls(r, func(control []byte) {
+		// delay in hundredths of a second
+		duration += int(binary.LittleEndian.Uint16(control[2:4])) * 10
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return frames, duration, nil
+}
+
+// gifControls walks the blocks of a GIF, calling visit with every graphic control extension
+// (block size, packed fields, delay and transparent color index). It returns the number of image descriptors
+func gifControls(r *bufio.Reader, visit func(control []byte)) (frames int, err error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if err := skipColorTable(r, header[10]); err != nil {
+		return 0, err
+	}
+
+	for {
+		introducer, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		switch introducer {
+		case 0x21: // extension
+			label, err := r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			if label == 0xf9 {
+				control := make([]byte, 5)
+				if _, err := io.ReadFull(r, control); err != nil {
+					return 0, err
+				}
+				visit(control)
+			}
+			if err := skipSubBlocks(r); err != nil {
+				return 0, err
+			}
+		case 0x2c: // image descriptor
+			descriptor := make([]byte, 9)
+			if _, err := io.ReadFull(r, descriptor); err != nil {
+				return 0, err
+			}
+			if err := skipColorTable(r, descriptor[8]); err != nil {
+				return 0, err
+			}
+			// LZW minimum code size
+			if _, err := r.ReadByte(); err != nil {
+				return 0, err
+			}
+			if err := skipSubBlocks(r); err != nil {
+				return 0, err
+			}
+			frames++
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, errInvalidAnimation
+		}
+	}
+}
+
+// skipColorTable skips the global or local color table described by the packed fields
+func skipColorTable(r *bufio.Reader, fields byte) error {
+	if fields&0x80 == 0 {
+		return nil
+	}
+	_, err := r.Discard(3 * (1 << (uint(fields&0x07) + 1)))
+	return err
+}
+
+func skipSubBlocks(r *bufio.Reader) error {
+	for {
+		size, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		if _, err := r.Discard(int(size)); err != nil {
+			return err
+		}
+	}
+}
+
+// webpAnimation counts the ANMF chunks of a WebP, and adds their durations
+func webpAnimation(r io.ReadSeeker) (frames int, duration int, err error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, err
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WEBP" {
+		return 0, 0, errInvalidAnimation
+	}
+
+	chunk := make([]byte, 8)
+	frame := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return 0, 0, err
+		}
+
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if string(chunk[0:4]) == "ANMF" {
+			if size < int64(len(frame)) {
+				return 0, 0, errInvalidAnimation
+			}
+			if _, err := io.ReadFull(r, frame); err != nil {
+				return 0, 0, err
+			}
+			frames++
+			duration += int(frame[12]) | int(frame[13])<<8 | int(frame[14])<<16
+			size -= int64(len(frame))
+		}
+
+		// chunks are padded to an even size
+		if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if frames == 0 {
+		// simple and extended formats without animation
+		frames = 1
+	}
+	return frames, duration, nil
+}
@@ -0,0 +1,190 @@
+package info
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"sort"
+
+	"github.com/image-server/image-server/icc"
+)
+
+// Colorspaces of ImageProperties
+const (
+	ColorspaceRGB  = "rgb"
+	ColorspaceCMYK = "cmyk"
+	ColorspaceGray = "gray"
+)
+
+// maximumProfileSize limits the ICC profiles read from the originals
+const maximumProfileSize = 4 << 20
+
+// colorspace returns the colorspace of the color model returned by image.DecodeConfig
+func colorspace(model color.Model) string {
+	switch model {
+	case color.CMYKModel:
+		return ColorspaceCMYK
+	case color.GrayModel, color.Gray16Model:
+		return ColorspaceGray
+	}
+	return ColorspaceRGB
+}
+
+// profileColorspace returns the colorspace of an ICC profile, or an empty string for other color spaces
+func profileColorspace(p *icc.Profile) string {
+	switch p.ColorSpace {
+	case "RGB":
+		return ColorspaceRGB
+	case "CMYK":
+		return ColorspaceCMYK
+	case "GRAY":
+		return ColorspaceGray
+	}
+	return ""
+}
+
+// colorProfile reads the embedded ICC profile of JPEG, PNG and WebP images.
+// It returns nil when the image has no profile
+func colorProfile(r io.ReadSeeker, contentType string) (*icc.Profile, error) {
+	var data []byte
+	var err error
+
+	switch contentType {
+	case "image/jpeg":
+		data, err = jpegProfile(r)
+	case "image/png":
+		return pngProfile(r)
+	case "image/webp":
+		data, err = webpProfile(r)
+	}
+
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return icc.Parse(data)
+}
+
+// jpegProfile joins the chunks of the APP2 ICC_PROFILE segments
+func jpegProfile(r io.ReadSeeker) ([]byte, error) {
+	chunks := map[int][]byte{}
+	size := 0
+
+	err := jpegSegments(r, func(marker byte, offset int64, length int64) (bool, error) {
+		if marker != 0xe2 || length < 14 {
+			return false, nil
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return false, err
+		}
+		if string(payload[0:12]) != "ICC_PROFILE\x00" {
+			return false, nil
+		}
+
+		size += len(payload) - 14
+		if size > maximumProfileSize {
+			return true, icc.ErrInvalidProfile
+		}
+		chunks[int(payload[12])] = payload[14:]
+		return false, nil
+	})
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	sequence := make([]int, 0, len(chunks))
+	for n := range chunks {
+		sequence = append(sequence, n)
+	}
+	sort.Ints(sequence)
+
+	data := make([]byte, 0, size)
+	for _, n := range sequence {
+		data = append(data, chunks[n]...)
+	}
+	return data, nil
+}
+
+// pngProfile reads the iCCP chunk, the sRGB chunk marks an sRGB image without a profile
+func pngProfile(r io.ReadSeeker) (*icc.Profile, error) {
+	if _, err := r.Seek(8, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		switch string(header[4:8]) {
+		case "iCCP":
+			if length > maximumProfileSize {
+				return nil, icc.ErrInvalidProfile
+			}
+			chunk := make([]byte, length)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, err
+			}
+			// profile name, null separator and compression method precede the profile
+			separator := bytes.IndexByte(chunk, 0)
+			if separator < 0 || separator+2 > len(chunk) {
+				return nil, icc.ErrInvalidProfile
+			}
+			zr, err := zlib.NewReader(bytes.NewReader(chunk[separator+2:]))
+			if err != nil {
+				return nil, err
+			}
+			data, err := ioutil.ReadAll(io.LimitReader(zr, maximumProfileSize))
+			if err != nil {
+				return nil, err
+			}
+			return icc.Parse(data)
+		case "sRGB":
+			return &icc.Profile{ColorSpace: "RGB", Description: "sRGB"}, nil
+		case "IDAT", "IEND":
+			return nil, nil
+		}
+
+		// skip the data and the crc
+		if _, err := r.Seek(length+4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// webpProfile reads the ICCP chunk of extended WebP images
+func webpProfile(r io.ReadSeeker) ([]byte, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		length := int64(binary.LittleEndian.Uint32(header[4:8]))
+		if string(header[0:4]) == "ICCP" {
+			if length > maximumProfileSize {
+				return nil, icc.ErrInvalidProfile
+			}
+			data := make([]byte, length)
+			_, err := io.ReadFull(r, data)
+			return data, err
+		}
+
+		// chunks are padded to an even size
+		if _, err := r.Seek(length+length%2, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package info
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// errUnknownHeader is returned when a header is not one of the formats read by headerDetails
+var errUnknownHeader = errors.New("unknown image header")
+
+var (
+	psdSignature          = []byte("8BPS")
+	jxlCodestreamSig      = []byte{0xff, 0x0a}
+	jxlContainerSignature = []byte{0x00, 0x00, 0x00, 0x0c, 'J', 'X', 'L', ' ', 0x0d, 0x0a, 0x87, 0x0a}
+)
+
+// ISO base media file brands of HEIF and AVIF images
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic",
+	"hevx": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+	"avif": "image/avif",
+	"avis": "image/avif",
+}
+
+// headerDetails reads the dimensions of formats that can't be decoded by the image package:
+// HEIC/HEIF, AVIF, PSD, JPEG XL and extended WebP (i.e. animations)
+func headerDetails(r io.ReadSeeker) (*ImageProperties, error) {
+	header := make([]byte, 32)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, psdSignature):
+		return psdDetails(header)
+	case bytes.HasPrefix(header, jxlCodestreamSig):
+		return jxlCodestreamDetails(header[len(jxlCodestreamSig):])
+	case bytes.HasPrefix(header, jxlContainerSignature):
+		return jxlContainerDetails(r)
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return heifDetails(r)
+	case len(header) >= 30 && string(header[0:4]) == "RIFF" && string(header[8:16]) == "WEBPVP8X":
+		return webpExtendedDetails(header)
+	}
+
+	return nil, errUnknownHeader
+}
+
+// psdDetails reads the file header of Photoshop documents (PSD and PSB)
+func psdDetails(header []byte) (*ImageProperties, error) {
+	if len(header) < 26 {
+		return nil, errUnknownHeader
+	}
+
+	return &ImageProperties{
+		Height:      int(binary.BigEndian.Uint32(header[14:18])),
+		Width:       int(binary.BigEndian.Uint32(header[18:22])),
+		ContentType: "image/vnd.adobe.photoshop",
+	}, nil
+}
+
+// webpExtendedDetails reads the canvas size of the VP8X chunk
+func webpExtendedDetails(header []byte) (*ImageProperties, error) {
+	canvas := header[24:30]
+	return &ImageProperties{
+		Width:       1 + (int(canvas[0]) | int(canvas[1])<<8 | int(canvas[2])<<16),
+		Height:      1 + (int(canvas[3]) | int(canvas[4])<<8 | int(canvas[5])<<16),
+		ContentType: "image/webp",
+	}, nil
+}
+
+// box is an ISO base media file format box (also used by the JPEG XL container)
+type box struct {
+	kind   string
+	offset int64
+	size   int64
+}
+
+// readBox reads the box header at the current offset. The size of the last box might be 0 (until the end of the file)
+func readBox(r io.ReadSeeker) (*box, error) {
+	offset, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	b := &box{kind: string(header[4:8]), offset: offset, size: int64(binary.BigEndian.Uint32(header[0:4]))}
+	headerSize := int64(8)
+	if b.size == 1 {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		b.size = int64(binary.BigEndian.Uint64(header))
+		headerSize = 16
+	}
+
+	if b.size != 0 && b.size < headerSize {
+		return nil, errUnknownHeader
+	}
+
+	return b, nil
+}
+
+// end returns the offset of the next box
+func (b *box) end() int64 {
+	return b.offset + b.size
+}
+
+// heifDetails reads the brand of the ftyp box and the image spatial extents (ispe) of meta/iprp/ipco.
+// Images might contain several extents (i.e. grid tiles or thumbnails), the largest one is used.
+// Decoders apply the rotation (irot) of the image, so the extent is swapped for quarter turns.
+// Alpha planes are auxiliary images with an alpha type (auxC)
+func heifDetails(r io.ReadSeeker) (*ImageProperties, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	ftyp, err := readBox(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if ftyp.size < 16 || ftyp.size > 4096 {
+		return nil, errUnknownHeader
+	}
+
+	data := make([]byte, ftyp.size-8)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, errUnknownHeader
+	}
+
+	contentType := heifBrands[string(data[0:4])]
+	for i := 8; contentType == "" && i+4 <= len(data); i += 4 {
+		contentType = heifBrands[string(data[i:i+4])]
+	}
+	if contentType == "" {
+		return nil, errUnknownHeader
+	}
+
+	details := &ImageProperties{ContentType: contentType}
+	quarterTurns := 0
+	err = walkBoxes(r, ftyp.end(), -1, func(b *box) error {
+		if b.kind == "irot" {
+			angle := make([]byte, 1)
+			if _, err := io.ReadFull(r, angle); err != nil {
+				return err
+			}
+			quarterTurns = int(angle[0] & 0x03)
+			return nil
+		}
+
+		if b.kind == "auxC" && b.size > 8 && b.size <= 4096 {
+			// auxiliary images of type alpha are alpha planes
+			urn := make([]byte, b.end()-b.offset-8)
+			if _, err := io.ReadFull(r, urn); err != nil {
+				return err
+			}
+			if bytes.Contains(urn, []byte("alpha")) {
+				details.HasAlpha = Alpha(true)
+			}
+			return nil
+		}
+
+		if b.kind != "ispe" {
+			return nil
+		}
+
+		extent := make([]byte, 12)
+		if _, err := io.ReadFull(r, extent); err != nil {
+			return err
+		}
+		width := int(binary.BigEndian.Uint32(extent[4:8]))
+		height := int(binary.BigEndian.Uint32(extent[8:12]))
+		if width*height > details.Width*details.Height {
+			details.Width, details.Height = width, height
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if details.Width == 0 || details.Height == 0 {
+		return nil, errUnknownHeader
+	}
+
+	if quarterTurns%2 == 1 {
+		details.Width, details.Height = details.Height, details.Width
+	}
+
+	return details, nil
+}
+
+// walkBoxes calls visit for every box between start and end (-1 for the end of the file),
+// descending into the meta, iprp and ipco containers. visit is called with the reader after the box header
+func walkBoxes(r io.ReadSeeker, start int64, end int64, visit func(*box) error) error {
+	for offset := start; end < 0 || offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		b, err := readBox(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch b.kind {
+		case "meta":
+			// full box, the version and flags precede the children
+			err = walkBoxes(r, b.offset+12, b.end(), visit)
+		case "iprp", "ipco":
+			err = walkBoxes(r, b.offset+8, b.end(), visit)
+		default:
+			err = visit(b)
+		}
+		if err != nil {
+			return err
+		}
+
+		if b.size == 0 {
+			return nil
+		}
+		offset = b.end()
+	}
+	return nil
+}
+
+// jxlContainerDetails reads the codestream header of the first jxlc or jxlp box
+func jxlContainerDetails(r io.ReadSeeker) (*ImageProperties, error) {
+	var details *ImageProperties
+	err := walkBoxes(r, int64(len(jxlContainerSignature)), -1, func(b *box) error {
+		if details != nil || (b.kind != "jxlc" && b.kind != "jxlp") {
+			return nil
+		}
+
+		if b.kind == "jxlp" {
+			// partial codestreams start with their index
+			if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+
+		header := make([]byte, 16)
+		n, err := io.ReadFull(r, header)
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		if !bytes.HasPrefix(header[:n], jxlCodestreamSig) {
+			return errUnknownHeader
+		}
+
+		details, err = jxlCodestreamDetails(header[len(jxlCodestreamSig):n])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if details == nil {
+		return nil, errUnknownHeader
+	}
+
+	return details, nil
+}
+
+// jxlRatios are the aspect ratios (width / height) of the JPEG XL size header
+var jxlRatios = [][2]int{{1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+
+// jxlCodestreamDetails reads the SizeHeader that follows the signature of a JPEG XL codestream
+func jxlCodestreamDetails(header []byte) (*ImageProperties, error) {
+	br := &bitReader{data: header}
+	var width, height int
+
+	small := br.read(1) == 1
+	if small {
+		height = (br.read(5) + 1) * 8
+	} else {
+		height = br.readU32()
+	}
+
+	ratio := br.read(3)
+	if ratio == 0 {
+		if small {
+			width = (br.read(5) + 1) * 8
+		} else {
+			width = br.readU32()
+		}
+	} else {
+		width = height * jxlRatios[ratio-1][0] / jxlRatios[ratio-1][1]
+	}
+
+	if br.overflow {
+		return nil, errUnknownHeader
+	}
+
+	return &ImageProperties{Height: height, Width: width, ContentType: "image/jxl"}, nil
+}
+
+// bitReader reads the least significant bits first, as in JPEG XL headers
+type bitReader struct {
+	data     []byte
+	position uint
+	overflow bool
+}
+
+func (br *bitReader) read(bits uint) int {
+	value := 0
+	for i := uint(0); i < bits; i++ {
+		index := br.position / 8
+		if int(index) >= len(br.data) {
+			br.overflow = true
+			return 0
+		}
+		bit := (br.data[index] >> (br.position % 8)) & 1
+		value |= int(bit) << i
+		br.position++
+	}
+	return value
+}
+
+// readU32 reads the dimensions distribution of the SizeHeader: 1 + u(9), 1 + u(13), 1 + u(18) or 1 + u(30)
+func (br *bitReader) readU32() int {
+	bits := []uint{9, 13, 18, 30}[br.read(2)]
+	return 1 + br.read(bits)
+}
@@ -4,13 +4,77 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+
+	"github.com/image-server/image-server/icc"
 )
 
 type ImageProperties struct {
-	Hash        string `json:"hash"`
-	Height      int    `json:"height"`
-	Width       int    `json:"width"`
-	ContentType string `json:"content_type"`
+	Hash        string      `json:"hash"`
+	Height      int         `json:"height"`
+	Width       int         `json:"width"`
+	ContentType string      `json:"content_type"`
+	FocalPoint  *FocalPoint `json:"focal_point,omitempty"`
+	// Colorspace of the original (rgb, cmyk or gray) and the description of its ICC profile
+	Colorspace string `json:"colorspace,omitempty"`
+	Profile    string `json:"profile,omitempty"`
+	// Orientation is the EXIF orientation of the original, Width and Height are already oriented
+	Orientation int `json:"orientation,omitempty"`
+	// Frames and Duration (milliseconds) are only present for animated images
+	Frames   int `json:"frames,omitempty"`
+	Duration int `json:"duration,omitempty"`
+	// HasAlpha is true when the original has transparency, it is missing from the details stored before
+	// the transparency was detected
+	HasAlpha *bool `json:"has_alpha,omitempty"`
+}
+
+// transparentContentTypes are the content types of originals that might have transparency
+var transparentContentTypes = map[string]bool{
+	"image/png":     true,
+	"image/gif":     true,
+	"image/webp":    true,
+	"image/svg+xml": true,
+}
+
+// Alpha returns the HasAlpha value of an image
+func Alpha(alpha bool) *bool {
+	return &alpha
+}
+
+// Animated returns true when the image has more than one frame
+func (d *ImageProperties) Animated() bool {
+	return d.Frames > 1
+}
+
+// Transparent returns true when the image has transparency. Details without HasAlpha are transparent
+// when the format supports transparency
+func (d *ImageProperties) Transparent() bool {
+	if d.HasAlpha != nil {
+		return *d.HasAlpha
+	}
+	return transparentContentTypes[d.ContentType]
+}
+
+// NeedsSRGBConversion returns true for CMYK images, and for color images with a profile other than sRGB
+func (d *ImageProperties) NeedsSRGBConversion() bool {
+	switch d.Colorspace {
+	case ColorspaceCMYK:
+		return true
+	case ColorspaceRGB:
+		return d.Profile != "" && !icc.IsSRGB(d.Profile)
+	}
+	return false
+}
+
+// FocalPoint is the point of interest of an image, crops are centered on it.
+// X and Y are fractions of the width and height (0.0 to 1.0)
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Valid returns true when both coordinates are within the image
+func (fp *FocalPoint) Valid() bool {
+	return fp.X >= 0 && fp.X <= 1 && fp.Y >= 0 && fp.Y <= 1
 }
 
 // ImageDetailsToJSON returns a string with JSON representation of the ImageDetails
@@ -22,6 +86,21 @@ func ImageDetailsToJSON(d *ImageProperties) (string, error) {
 	return string(b), nil
 }
 
+// LoadImageDetail reads ImageDetails from a JSON file on "path"
+func LoadImageDetail(path string) (*ImageProperties, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &ImageProperties{}
+	err = json.Unmarshal(b, d)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 // SaveImageDetail saves ImageDetails in a JSON file on "path"
 func SaveImageDetail(d *ImageProperties, path string) error {
 	json, err := ImageDetailsToJSON(d)
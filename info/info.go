@@ -1,6 +1,7 @@
 package info
 
 import (
+	"context"
 	"crypto/md5"
 	"errors"
 	"fmt"
@@ -12,15 +13,26 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
+	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/mime"
+	"github.com/image-server/image-server/processor/limits"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	_ "golang.org/x/image/webp"
 )
 
+// IdentifyCommand reads the properties of the images Go can't decode with IdentifyFormat, the width, height,
+// format and transparency of the image. They are set by the selected processor, with the variables of its limits
+var (
+	IdentifyCommand = []string{"identify"}
+	IdentifyFormat  = "%[fx:w]:%[fx:h]:%m:%A"
+	IdentifyLimits  = limits.ImageMagick
+)
+
 type Info struct {
 	Path        string
 	ContentType string
@@ -58,7 +70,11 @@ func (i Info) ImageDetails() (*ImageProperties, error) {
 				Height:      im.Height,
 				Width:       im.Width,
 				ContentType: contentType,
+				Colorspace:  colorspace(im.ColorModel),
+				HasAlpha:    Alpha(alphaModel(im.ColorModel)),
 			}
+		} else if headerDetails, err := i.headerDetails(reader); err == nil {
+			details = headerDetails
 		} else if i.ContentType == "image/svg+xml" {
 			// Imagemagick is unable to determine svg filetype without file format
 			details = &ImageProperties{
@@ -73,6 +89,41 @@ func (i Info) ImageDetails() (*ImageProperties, error) {
 			}
 		}
 
+		profile, err := colorProfile(reader, details.ContentType)
+		if err != nil {
+			glog.Infof("Can't read the color profile of the image: %s", err)
+		} else if profile != nil {
+			details.Profile = profile.Description
+			if c := profileColorspace(profile); c != "" {
+				details.Colorspace = c
+			}
+		}
+
+		orientation, err := orientation(reader, details.ContentType)
+		if err != nil {
+			glog.Infof("Can't read the orientation of the image: %s", err)
+		} else if orientation > 1 {
+			details.Orientation = orientation
+			if transposed(orientation) {
+				details.Width, details.Height = details.Height, details.Width
+			}
+		}
+
+		frames, duration, err := animation(reader, details.ContentType)
+		if err != nil {
+			glog.Infof("Can't read the frames of the image: %s", err)
+		} else if frames > 1 {
+			details.Frames = frames
+			details.Duration = duration
+		}
+
+		alpha, err := hasAlpha(reader, details.ContentType)
+		if err != nil {
+			glog.Infof("Can't read the transparency of the image: %s", err)
+		} else if alpha || details.HasAlpha == nil {
+			details.HasAlpha = Alpha(alpha)
+		}
+
 		hash, err := i.FileHash()
 		details.Hash = hash
 		return details, nil
@@ -89,13 +140,12 @@ func (i Info) DetailsFromImageMagick() (*ImageProperties, error) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	args := []string{"-format", "%[fx:w]:%[fx:h]:%m", i.Path}
-	cmd := exec.Command("identify", args...)
-	cmd.Env = []string{"TMPDIR=" + tmpDir, "MAGICK_DISK_LIMIT=100000000"}
-	out, err := cmd.Output()
+	args := []string{"-format", IdentifyFormat, i.Path}
+	command := append(append([]string{}, IdentifyCommand...), args...)
+	out, err := limits.Run(context.Background(), IdentifyLimits, command, []string{"TMPDIR=" + tmpDir})
 
 	if err != nil {
-		return nil, fmt.Errorf("ImageMagick failed to identify properties")
+		return nil, limits.Failure(err, "ImageMagick failed to identify properties")
 	}
 
 	dimensions := fmt.Sprintf("%s", out)
@@ -121,21 +171,33 @@ func (i Info) DetailsFromImageMagick() (*ImageProperties, error) {
 		return nil, err
 	}
 
+	// the alpha channel is "False" or "Undefined" for opaque images, GraphicsMagick prints "false"
+	alpha := len(d) > 3 && (strings.EqualFold(d[3], "true") || d[3] == "Blend")
+
 	return &ImageProperties{
 		Height:      h,
 		Width:       w,
 		ContentType: contentType,
+		HasAlpha:    Alpha(alpha),
 	}, nil
 }
 
+// headerDetails reads the dimensions from the header of HEIC/HEIF, AVIF, PSD and JPEG XL files
+func (i Info) headerDetails(reader io.ReadSeeker) (*ImageProperties, error) {
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return headerDetails(reader)
+}
+
 func getContentTypeFromExtension(format string) (string, error) {
 	if format == "" {
 		return "", errors.New("Can't extract format")
 	}
 
-	contentType := mime.ExtToContentType(format)
+	contentType := mime.FormatToContentType(format)
 	if contentType == "" {
-		return "", fmt.Errorf("Can't extract content type from format. format=%s, contentType=%s", format, contentType)
+		return "", core.NewError(core.ErrorUnsupportedFormat, "Can't extract content type from format. format=%s, contentType=%s", format, contentType)
 	}
 
 	return contentType, nil
@@ -83,7 +83,7 @@ func TestImageDetailOnSvg(t *testing.T) {
 }
 
 func TestImageDetailsToJSON(t *testing.T) {
-	d := &info.ImageProperties{"THISISAHASH", 10, 20, "image/jpeg"}
+	d := &info.ImageProperties{Hash: "THISISAHASH", Height: 10, Width: 20, ContentType: "image/jpeg"}
 	json, err := info.ImageDetailsToJSON(d)
 	expected := "{\"hash\":\"THISISAHASH\",\"height\":10,\"width\":20,\"content_type\":\"image/jpeg\"}"
 	Ok(t, err)
@@ -92,7 +92,7 @@ func TestImageDetailsToJSON(t *testing.T) {
 
 func TestSaveImageDetail(t *testing.T) {
 	path := "../test/test-image-detail.json"
-	d := &info.ImageProperties{"THISISAHASH", 10, 20, "image/jpeg"}
+	d := &info.ImageProperties{Hash: "THISISAHASH", Height: 10, Width: 20, ContentType: "image/jpeg"}
 	info.SaveImageDetail(d, path)
 
 	fileBuffer, err := ioutil.ReadFile(path)
@@ -105,7 +105,194 @@ func TestSaveImageDetail(t *testing.T) {
 	os.Remove(path)
 }
 
+func TestImageDetailsToJSONWithFocalPoint(t *testing.T) {
+	d := &info.ImageProperties{Hash: "THISISAHASH", Height: 10, Width: 20, ContentType: "image/jpeg", FocalPoint: &info.FocalPoint{X: 0.25, Y: 0.5}}
+	json, err := info.ImageDetailsToJSON(d)
+	expected := "{\"hash\":\"THISISAHASH\",\"height\":10,\"width\":20,\"content_type\":\"image/jpeg\",\"focal_point\":{\"x\":0.25,\"y\":0.5}}"
+	Ok(t, err)
+	Equals(t, expected, json)
+}
+
+func TestLoadImageDetail(t *testing.T) {
+	path := "../test/test-load-image-detail.json"
+	d := &info.ImageProperties{Hash: "THISISAHASH", Height: 10, Width: 20, ContentType: "image/jpeg", FocalPoint: &info.FocalPoint{X: 0.25, Y: 0.5}}
+	info.SaveImageDetail(d, path)
+	defer os.Remove(path)
+
+	loaded, err := info.LoadImageDetail(path)
+	Ok(t, err)
+	Equals(t, d, loaded)
+}
+
+func TestImageDetailsOnTIFF(t *testing.T) {
+	i := info.Info{Path: "../test/images/a.tiff"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 496, imageDetails.Height)
+	Equals(t, 574, imageDetails.Width)
+	Equals(t, "image/tiff", imageDetails.ContentType)
+}
+
+func TestImageDetailsOnBMP(t *testing.T) {
+	i := info.Info{Path: "../test/images/a.bmp"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 48, imageDetails.Height)
+	Equals(t, 64, imageDetails.Width)
+	Equals(t, "image/bmp", imageDetails.ContentType)
+}
+
+func TestImageDetailsOnPSD(t *testing.T) {
+	i := info.Info{Path: "../test/images/a.psd"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 48, imageDetails.Height)
+	Equals(t, 64, imageDetails.Width)
+	Equals(t, "image/vnd.adobe.photoshop", imageDetails.ContentType)
+}
+
+func TestImageDetailsOnHEIC(t *testing.T) {
+	// only the header of a 4032x3024 photo, with 512x512 grid tiles
+	i := info.Info{Path: "../test/images/header_only.heic"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 3024, imageDetails.Height)
+	Equals(t, 4032, imageDetails.Width)
+	Equals(t, "image/heic", imageDetails.ContentType)
+	Equals(t, 32, len(imageDetails.Hash))
+}
+
+func TestImageDetailsOnJXL(t *testing.T) {
+	// only the container and the size header of an 800x600 codestream
+	i := info.Info{Path: "../test/images/header_only.jxl"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 600, imageDetails.Height)
+	Equals(t, 800, imageDetails.Width)
+	Equals(t, "image/jxl", imageDetails.ContentType)
+}
+
+func TestImageDetailsOnAnimatedGIF(t *testing.T) {
+	i := info.Info{Path: "../test/images/animated.gif"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 48, imageDetails.Height)
+	Equals(t, 64, imageDetails.Width)
+	Equals(t, "image/gif", imageDetails.ContentType)
+	Equals(t, 3, imageDetails.Frames)
+	Equals(t, 600, imageDetails.Duration)
+	Equals(t, true, imageDetails.Animated())
+}
+
+func TestImageDetailsOnAnimatedWEBP(t *testing.T) {
+	i := info.Info{Path: "../test/images/animated.webp"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 496, imageDetails.Height)
+	Equals(t, 574, imageDetails.Width)
+	Equals(t, "image/webp", imageDetails.ContentType)
+	Equals(t, 2, imageDetails.Frames)
+	Equals(t, 350, imageDetails.Duration)
+}
+
+func TestImageDetailsOnStillWEBP(t *testing.T) {
+	i := info.Info{Path: "../test/images/a.webp"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 0, imageDetails.Frames)
+	Equals(t, false, imageDetails.Animated())
+}
+
+func TestImageDetailsOnOrientedJPEG(t *testing.T) {
+	// stored as 64x48, rotated 90 degrees clockwise by its EXIF orientation
+	i := info.Info{Path: "../test/images/orientation_6.jpg"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 64, imageDetails.Height)
+	Equals(t, 48, imageDetails.Width)
+	Equals(t, 6, imageDetails.Orientation)
+}
+
+func TestImageDetailsOnJPEGWithoutOrientation(t *testing.T) {
+	i := info.Info{Path: "../test/images/a.jpg"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 0, imageDetails.Orientation)
+}
+
+func TestImageDetailsOnRotatedHEIC(t *testing.T) {
+	i := info.Info{Path: "../test/images/header_only_rotated.heic"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 4032, imageDetails.Height)
+	Equals(t, 3024, imageDetails.Width)
+	Equals(t, 0, imageDetails.Orientation)
+}
+
+func TestImageDetailsOnDisplayP3JPEG(t *testing.T) {
+	i := info.Info{Path: "../test/images/display_p3.jpg"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, info.ColorspaceRGB, imageDetails.Colorspace)
+	Equals(t, "Display P3", imageDetails.Profile)
+}
+
+func TestImageDetailsOnDisplayP3PNG(t *testing.T) {
+	i := info.Info{Path: "../test/images/display_p3.png"}
+	imageDetails, err := i.ImageDetails()
+	Ok(t, err)
+	Equals(t, 16, imageDetails.Width)
+	Equals(t, info.ColorspaceRGB, imageDetails.Colorspace)
+	Equals(t, "Display P3", imageDetails.Profile)
+}
+
 func fileExists(path string) bool {
 	_, err := os.Stat(path)
 	return os.IsNotExist(err)
 }
+
+func TestImageDetailsHasAlpha(t *testing.T) {
+	for _, path := range []string{"transparent.png", "transparent.gif", "header_only_alpha.webp"} {
+		i := info.Info{Path: "../test/images/" + path}
+		imageDetails, err := i.ImageDetails()
+		Ok(t, err)
+		Assert(t, imageDetails.Transparent(), "expected %s to have alpha", path)
+	}
+
+	for _, path := range []string{"a.png", "a.jpg", "a.webp", "animated.gif"} {
+		i := info.Info{Path: "../test/images/" + path}
+		imageDetails, err := i.ImageDetails()
+		Ok(t, err)
+		Assert(t, !imageDetails.Transparent(), "expected %s to be opaque", path)
+	}
+}
+
+func TestTransparentWithoutHasAlpha(t *testing.T) {
+	// details stored before the transparency was detected
+	Equals(t, true, (&info.ImageProperties{ContentType: "image/png"}).Transparent())
+	Equals(t, false, (&info.ImageProperties{ContentType: "image/jpeg"}).Transparent())
+	Equals(t, false, (&info.ImageProperties{ContentType: "image/png", HasAlpha: info.Alpha(false)}).Transparent())
+}
+
+func TestDetailsFromGraphicsMagick(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gm")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	// gm identify prints the transparency in lower case
+	script := dir + "/gm"
+	Ok(t, ioutil.WriteFile(script, []byte("#!/bin/sh\n[ \"$1 $2 $3\" = \"identify -format %w:%h:%m:%A\" ] && echo 640:480:TIFF:true\n"), 0700))
+
+	command, format := info.IdentifyCommand, info.IdentifyFormat
+	defer func() { info.IdentifyCommand, info.IdentifyFormat = command, format }()
+	info.IdentifyCommand = []string{script, "identify"}
+	info.IdentifyFormat = "%w:%h:%m:%A"
+
+	i := info.Info{Path: "../test/images/a.tiff"}
+	imageDetails, err := i.DetailsFromImageMagick()
+	Ok(t, err)
+	Equals(t, 640, imageDetails.Width)
+	Equals(t, 480, imageDetails.Height)
+	Equals(t, "image/tiff", imageDetails.ContentType)
+	Equals(t, true, imageDetails.Transparent())
+}
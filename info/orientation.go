@@ -0,0 +1,144 @@
+package info
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errInvalidExif = errors.New("invalid exif")
+
+const orientationTag = 0x0112
+
+// orientation reads the EXIF orientation of JPEG and TIFF images (1 to 8), it is 1 when the tag is missing
+func orientation(r io.ReadSeeker, contentType string) (int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return jpegOrientation(r)
+	case "image/tiff":
+		return tiffOrientation(r, 0)
+	}
+	return 1, nil
+}
+
+// transposed returns true for the orientations that swap the width and height of the image
+func transposed(orientation int) bool {
+	return orientation >= 5 && orientation <= 8
+}
+
+// jpegOrientation looks for the Exif APP1 segment before the start of the scan
+func jpegOrientation(r io.ReadSeeker) (int, error) {
+	orientation := 1
+	err := jpegSegments(r, func(marker byte, offset int64, length int64) (bool, error) {
+		if marker != 0xe1 || length < 12 {
+			return false, nil
+		}
+
+		id := make([]byte, 6)
+		if _, err := io.ReadFull(r, id); err != nil {
+			return false, err
+		}
+		if string(id) != "Exif\x00\x00" {
+			return false, nil
+		}
+
+		var err error
+		orientation, err = tiffOrientation(r, offset+6)
+		return true, err
+	})
+	return orientation, err
+}
+
+// jpegSegments calls visit with the marker, offset and length of the payload of every segment
+// before the start of the scan, until visit is done
+func jpegSegments(r io.ReadSeeker, visit func(marker byte, offset int64, length int64) (bool, error)) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	soi := make([]byte, 2)
+	if _, err := io.ReadFull(r, soi); err != nil {
+		return err
+	}
+	if soi[0] != 0xff || soi[1] != 0xd8 {
+		return errInvalidExif
+	}
+
+	offset := int64(2)
+	segment := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return err
+		}
+		if segment[0] != 0xff {
+			return errInvalidExif
+		}
+
+		marker := segment[1]
+		if marker == 0xda || marker == 0xd9 {
+			// start of scan or end of image
+			return nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(segment[2:4])) - 2
+		done, err := visit(marker, offset+4, length)
+		if done || err != nil {
+			return err
+		}
+
+		offset += 4 + length
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+}
+
+// tiffOrientation reads the orientation tag of the first IFD of the TIFF structure at base
+func tiffOrientation(r io.ReadSeeker, base int64) (int, error) {
+	if _, err := r.Seek(base, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+
+	var order binary.ByteOrder
+	switch string(header[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, errInvalidExif
+	}
+
+	if _, err := r.Seek(base+int64(order.Uint32(header[4:8])), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	count := make([]byte, 2)
+	if _, err := io.ReadFull(r, count); err != nil {
+		return 0, err
+	}
+
+	entries := make([]byte, 12*int(order.Uint16(count)))
+	if _, err := io.ReadFull(r, entries); err != nil {
+		return 0, err
+	}
+
+	for e := 0; e < len(entries); e += 12 {
+		if order.Uint16(entries[e:e+2]) != orientationTag {
+			continue
+		}
+		// SHORT values are stored in the first bytes of the value field
+		value := int(order.Uint16(entries[e+8 : e+10]))
+		if value < 1 || value > 8 {
+			return 1, nil
+		}
+		return value, nil
+	}
+
+	return 1, nil
+}
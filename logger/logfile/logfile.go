@@ -31,6 +31,10 @@ func (l *Logger) ImageAlreadyProcessed(ic *core.ImageConfiguration) {
 func (l *Logger) ImageProcessedWithErrors(ic *core.ImageConfiguration) {
 }
 
+func (l *Logger) QualitySelected(ic *core.ImageConfiguration) {
+	glog.Infof("Selected quality: filename=%v mode=%v quality=%v", ic.Filename, ic.QualityMode(), ic.Quality)
+}
+
 func (l *Logger) AllImagesAlreadyProcessed(namespace string, hash string, sourceURL string) {
 	glog.Warningf("All images already processed: namespace=%v hash=%v source=%v", namespace, hash, sourceURL)
 }
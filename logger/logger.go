@@ -42,6 +42,12 @@ func ImageProcessedWithErrors(ic *core.ImageConfiguration) {
 	}
 }
 
+func QualitySelected(ic *core.ImageConfiguration) {
+	for _, logger := range Loggers {
+		go logger.QualitySelected(ic)
+	}
+}
+
 func AllImagesAlreadyProcessed(namespace string, hash string, sourceURL string) {
 	for _, logger := range Loggers {
 		go logger.AllImagesAlreadyProcessed(namespace, hash, sourceURL)
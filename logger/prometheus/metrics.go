@@ -15,6 +15,7 @@ type Metrics struct {
 	imageAlreadyProcessedMetric     *prometheus.CounterVec
 	imageProcessedWithErrorsMetric  *prometheus.CounterVec
 	allImagesAlreadyProcessedMetric *prometheus.CounterVec
+	selectedQuality                 *prometheus.HistogramVec
 	sourceDownloadedMetric          prometheus.Counter
 	originalDownloadedMetric        prometheus.Counter
 	originalDownloadFailedMetric    prometheus.Counter
@@ -88,6 +89,16 @@ func CreateAndRegisterMetrics() *Metrics {
 	)
 	prometheus.MustRegister(metrics.allImagesAlreadyProcessedMetric)
 
+	metrics.selectedQuality = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "image_server_processing_selected_quality",
+			Help:    "Quality chosen for outputs with an automatic quality",
+			Buckets: prometheus.LinearBuckets(10, 10, 10),
+		},
+		[]string{"namespace", "format", "mode"},
+	)
+	prometheus.MustRegister(metrics.selectedQuality)
+
 	metrics.sourceDownloadedMetric = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "image_server_fetch_source_downloaded_total",
@@ -47,6 +47,11 @@ func (l *Logger) ImageProcessedWithErrors(ic *core.ImageConfiguration) {
 	l.metrics.imageProcessedWithErrorsMetric.WithLabelValues(ic.Namespace, ic.Format, fmt.Sprint(ic.Quality)).Inc()
 }
 
+// QualitySelected observes the quality chosen for an output by its quality mode
+func (l *Logger) QualitySelected(ic *core.ImageConfiguration) {
+	l.metrics.selectedQuality.WithLabelValues(ic.Namespace, ic.Format, ic.QualityMode()).Observe(float64(ic.Quality))
+}
+
 // AllImagesAlreadyProcessed posts an all images already processed metric
 func (l *Logger) AllImagesAlreadyProcessed(namespace string, hash string, sourceURL string) {
 	l.metrics.allImagesAlreadyProcessedMetric.WithLabelValues(namespace).Inc()
@@ -47,6 +47,10 @@ func (l *Logger) ImageProcessedWithErrors(ic *core.ImageConfiguration) {
 	l.track("processing.version.failed." + ic.Format)
 }
 
+func (l *Logger) QualitySelected(ic *core.ImageConfiguration) {
+	l.statsd.Gauge(fmt.Sprintf("processing.quality.%s.%s", ic.QualityMode(), ic.Format), int64(ic.Quality))
+}
+
 func (l *Logger) AllImagesAlreadyProcessed(namespace string, hash string, sourceURL string) {
 	l.track("processing.versions.noop")
 }
@@ -5,16 +5,40 @@ import (
 	"strings"
 )
 
+// formats maps the format names of Go decoders and ImageMagick (%m) to content types
+var formats = map[string]string{
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"bmp":  "image/bmp",
+	"bmp2": "image/bmp",
+	"bmp3": "image/bmp",
+	"heic": "image/heic",
+	"heif": "image/heif",
+	"avif": "image/avif",
+	"jxl":  "image/jxl",
+	"psd":  "image/vnd.adobe.photoshop",
+	"psb":  "image/vnd.adobe.photoshop",
+	"svg":  "image/svg+xml",
+	"pdf":  "application/pdf",
+}
+
 // ExtToContentType returns the content type for a given file extension.
 // The content type is retuned in the header when serving images
 func ExtToContentType(ext string) string {
 	ext = strings.ToLower(ext)
-	switch ext {
-	case "jpg":
-		return "image/jpeg"
-	case "pdf":
-		return "application/pdf"
-	default:
-		return fmt.Sprintf("image/%s", ext)
+	if contentType, ok := formats[ext]; ok {
+		return contentType
 	}
+	return fmt.Sprintf("image/%s", ext)
+}
+
+// FormatToContentType returns the content type of a decoded image format, i.e. "TIFF".
+// It returns an empty string for unknown formats
+func FormatToContentType(format string) string {
+	return formats[strings.ToLower(format)]
 }
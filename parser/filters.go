@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/image-server/image-server/core"
+)
+
+var reRotate, reBlur, reSharpen, reBrightnessContrast *regexp.Regexp
+
+// filterOrder is the order of the filters in filenames, it is the order in which they are applied.
+// A single order keeps a single filename for each output
+var filterOrder = []string{"trim", "rotate", "flip", "flop", "gray", "bc", "blur", "sharpen", "circle"}
+
+func init() {
+	reRotate = regexp.MustCompile(`^rotate(90|180|270)$`)
+	reBlur = regexp.MustCompile(`^blur([0-9]+)$`)
+	reSharpen = regexp.MustCompile(`^sharpen([0-9]+)$`)
+	// brightness and contrast, "m" is the minus sign, i.e. bc10_m20
+	reBrightnessContrast = regexp.MustCompile(`^bc(m?[0-9]+)_(m?[0-9]+)$`)
+}
+
+// applyFilter sets a filter option on the image configuration.
+// It returns the name of the filter, or an empty string when the option is not a filter
+func applyFilter(ic *core.ImageConfiguration, option string) (string, error) {
+	switch option {
+	case "trim":
+		ic.Trim = true
+		return option, nil
+	case "flip":
+		ic.Flip = true
+		return option, nil
+	case "flop":
+		ic.Flop = true
+		return option, nil
+	case "gray":
+		ic.Gray = true
+		return option, nil
+	case "circle":
+		if ic.Width == 0 || ic.Width != ic.Height {
+			return "", core.NewPolicyError("circle requires a square output: %s", ic.Filename)
+		}
+		ic.Circle = true
+		return option, nil
+	}
+
+	if m := reRotate.FindStringSubmatch(option); m != nil {
+		ic.Rotate, _ = strconv.Atoi(m[1])
+		return "rotate", nil
+	}
+
+	if m := reBlur.FindStringSubmatch(option); m != nil {
+		ic.Blur, _ = strconv.Atoi(m[1])
+		if ic.Blur < 1 || ic.Blur > 50 {
+			return "", core.NewPolicyError("blur must be between 1 and 50: %s", ic.Filename)
+		}
+		return "blur", nil
+	}
+
+	if m := reSharpen.FindStringSubmatch(option); m != nil {
+		ic.Sharpen, _ = strconv.Atoi(m[1])
+		if ic.Sharpen < 1 || ic.Sharpen > 10 {
+			return "", core.NewPolicyError("sharpen must be between 1 and 10: %s", ic.Filename)
+		}
+		return "sharpen", nil
+	}
+
+	if m := reBrightnessContrast.FindStringSubmatch(option); m != nil {
+		ic.Brightness = signedValue(m[1])
+		ic.Contrast = signedValue(m[2])
+		if !percentage(ic.Brightness) || !percentage(ic.Contrast) || (ic.Brightness == 0 && ic.Contrast == 0) {
+			return "", core.NewPolicyError("brightness and contrast must be between -100 and 100: %s", ic.Filename)
+		}
+		return "bc", nil
+	}
+
+	return "", nil
+}
+
+// checkFilterOrder returns an error when the filters are not in filterOrder, or when a filter is repeated
+func checkFilterOrder(ic *core.ImageConfiguration, filters []string) error {
+	last := -1
+	for _, filter := range filters {
+		position := -1
+		for i, f := range filterOrder {
+			if f == filter {
+				position = i
+			}
+		}
+
+		if position <= last {
+			return core.NewPolicyError("filters must be in the order %s: %s", strings.Join(filterOrder, ", "), ic.Filename)
+		}
+		last = position
+	}
+	return nil
+}
+
+func signedValue(value string) int {
+	if strings.HasPrefix(value, "m") {
+		v, _ := strconv.Atoi(value[1:])
+		return -v
+	}
+	v, _ := strconv.Atoi(value)
+	return v
+}
+
+func percentage(value int) bool {
+	return value >= -100 && value <= 100
+}
@@ -7,50 +7,132 @@ import (
 	"github.com/image-server/image-server/core"
 )
 
-var reR, reS, reW, reF, reFormat *regexp.Regexp
+var reR, reS, reW, reF, reFormat, reRegion *regexp.Regexp
 
 func init() {
-	reR = regexp.MustCompile(`^([0-9]+)x([0-9]+)(?:-q([0-9]+))?\.(\w{3,5})$`)
-	reS = regexp.MustCompile(`^x([0-9]+)(?:-q([0-9]+))?\.(\w{3,5})$`)
-	reW = regexp.MustCompile(`^w([0-9]+)(?:-q([0-9]+))?\.(\w{3,5})$`)
-	reF = regexp.MustCompile(`^full_size(?:-q([0-9]+))?\.(\w{3,5})$`)
+	reR = regexp.MustCompile(`^(([0-9]+)x([0-9]+))((?:-[a-z0-9_]+)*)\.(\w{3,5})$`)
+	reS = regexp.MustCompile(`^(x([0-9]+))((?:-[a-z0-9_]+)*)\.(\w{3,5})$`)
+	reW = regexp.MustCompile(`^(w([0-9]+))((?:-[a-z0-9_]+)*)\.(\w{3,5})$`)
+	reF = regexp.MustCompile(`^(full_size)((?:-[a-z0-9_]+)*)\.(\w{3,5})$`)
+	// Region of the original in pixels (x,y,width,height) followed by an output, i.e. c10,20,400,300-w200.jpg
+	reRegion = regexp.MustCompile(`^c([0-9]+),([0-9]+),([0-9]+),([0-9]+)-(.+)$`)
 	// Custom file name i.e. original.png, some-image-name.png, my-file.png
 	reFormat = regexp.MustCompile(`^.+\.(\w{3,5})$`)
 }
 
-func NameToConfiguration(sc *core.ServerConfiguration, filename string) (*core.ImageConfiguration, error) {
-	var w, h, q, f string
-	var quality uint
-
-	if reR.MatchString(filename) {
-		m := reR.FindStringSubmatch(filename)
-		w, h, q, f = m[1], m[2], m[3], m[4]
-	} else if reS.MatchString(filename) {
-		m := reS.FindStringSubmatch(filename)
-		w, h, q, f = m[1], m[1], m[2], m[3]
-	} else if reW.MatchString(filename) {
-		m := reW.FindStringSubmatch(filename)
-		w, h, q, f = m[1], "0", m[2], m[3]
-	} else if reF.MatchString(filename) {
-		m := reF.FindStringSubmatch(filename)
-		w, h, q, f = "0", "0", m[1], m[2]
-	} else {
-		if reFormat.MatchString(filename) {
-			f = reFormat.FindStringSubmatch(filename)[1]
-		}
+// NameToConfiguration returns the configuration used to generate the output filename in a namespace.
+// Presets of the namespace are expanded, and the dimensions are validated against the namespace allowlist
+func NameToConfiguration(sc *core.ServerConfiguration, namespace string, filename string) (*core.ImageConfiguration, error) {
+	var d, w, h, o, f string
 
-		return &core.ImageConfiguration{Filename: filename, Format: f}, nil
+	output := filename
+	region, err := parseRegion(filename)
+	if err != nil {
+		return nil, err
+	}
+	if region != nil {
+		output = reRegion.FindStringSubmatch(filename)[5]
 	}
 
-	width, _ := strconv.Atoi(w)
-	height, _ := strconv.Atoi(h)
-	quality64, _ := strconv.ParseUint(q, 10, 0)
+	nc := sc.Namespace(namespace)
+	name, isPreset := nc.Preset(output)
+	if !isPreset {
+		name = output
+	}
 
-	if quality64 > 0 {
-		quality = uint(quality64)
+	if reR.MatchString(name) {
+		m := reR.FindStringSubmatch(name)
+		d, w, h, o, f = m[1], m[2], m[3], m[4], m[5]
+	} else if reS.MatchString(name) {
+		m := reS.FindStringSubmatch(name)
+		d, w, h, o, f = m[1], m[2], m[2], m[3], m[4]
+	} else if reW.MatchString(name) {
+		m := reW.FindStringSubmatch(name)
+		d, w, h, o, f = m[1], m[2], "0", m[3], m[4]
+	} else if reF.MatchString(name) {
+		m := reF.FindStringSubmatch(name)
+		d, w, h, o, f = m[1], "0", "0", m[2], m[3]
 	} else {
-		quality = sc.DefaultQuality
+		return customConfiguration(namespace, filename), nil
+	}
+
+	// dimensions overflowing an int are not the original size
+	width, err := strconv.Atoi(w)
+	if err != nil {
+		return nil, core.NewPolicyError("width of %s is out of range", filename)
+	}
+	height, err := strconv.Atoi(h)
+	if err != nil {
+		return nil, core.NewPolicyError("height of %s is out of range", filename)
+	}
+
+	ic := &core.ImageConfiguration{Width: width, Height: height, Format: f, Filename: filename, Namespace: namespace, Region: region}
+
+	recognized, err := applyOptions(ic, o)
+	if err != nil {
+		return nil, err
+	}
+	if !recognized {
+		return customConfiguration(namespace, filename), nil
+	}
+
+	if !isPreset && !nc.AllowsDimension(d) {
+		return nil, core.NewPolicyError("dimension %s is not allowed in namespace %s", d, namespace)
+	}
+
+	err = sc.CheckDimensions(ic.Width, ic.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	if ic.AutoQuality {
+		ic.SSIM = sc.AutoQualitySSIM
+	} else if ic.Quality == 0 && ic.TargetSize == 0 {
+		ic.Quality = sc.DefaultQualityFor(namespace, f)
+	}
+
+	if ic.Metadata == "" {
+		ic.Metadata = sc.MetadataFor(namespace)
+	}
+
+	if ic.Background == "" {
+		ic.Background = sc.BackgroundFor(namespace)
+	}
+
+	ic.EncoderConfiguration.Merge(sc.EncoderFor(namespace, f))
+
+	return ic, nil
+}
+
+// parseRegion returns the region of the original cropped by the output, or nil when the output has no region
+func parseRegion(filename string) (*core.Region, error) {
+	m := reRegion.FindStringSubmatch(filename)
+	if m == nil {
+		return nil, nil
+	}
+
+	var values [4]int
+	for i := range values {
+		v, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return nil, core.NewPolicyError("region of %s is out of range", filename)
+		}
+		values[i] = v
+	}
+	region := &core.Region{X: values[0], Y: values[1], Width: values[2], Height: values[3]}
+
+	if region.Width == 0 || region.Height == 0 {
+		return nil, core.NewPolicyError("region of %s is empty", filename)
+	}
+	return region, nil
+}
+
+// customConfiguration is used for files that do not follow the output grammar
+func customConfiguration(namespace string, filename string) *core.ImageConfiguration {
+	var f string
+	if reFormat.MatchString(filename) {
+		f = reFormat.FindStringSubmatch(filename)[1]
 	}
 
-	return &core.ImageConfiguration{Width: width, Height: height, Quality: quality, Format: f, Filename: filename}, nil
+	return &core.ImageConfiguration{Filename: filename, Format: f, Namespace: namespace}
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/image-server/image-server/core"
+	. "github.com/image-server/image-server/test"
 )
 
 func ensureImageConfiguration(t *testing.T, ic *core.ImageConfiguration, w int, h int, q uint, f string) {
@@ -32,48 +33,266 @@ func init() {
 // Use the default quality
 
 func TestRectangle(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "300x400.jpg")
+	ic, _ := NameToConfiguration(sc, "", "300x400.jpg")
 	ensureImageConfiguration(t, ic, 300, 400, 75, "jpg")
 }
 
 func TestSquare(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "x300.jpg")
+	ic, _ := NameToConfiguration(sc, "", "x300.jpg")
 	ensureImageConfiguration(t, ic, 300, 300, 75, "jpg")
 }
 
 func TestWidth(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "w300.jpg")
+	ic, _ := NameToConfiguration(sc, "", "w300.jpg")
 	ensureImageConfiguration(t, ic, 300, 0, 75, "jpg")
 }
 
 func TestFullSize(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "full_size.jpg")
+	ic, _ := NameToConfiguration(sc, "", "full_size.jpg")
 	ensureImageConfiguration(t, ic, 0, 0, 75, "jpg")
 }
 
 func TestUnsupported(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "random.jpg")
+	ic, _ := NameToConfiguration(sc, "", "random.jpg")
 	ensureImageConfiguration(t, ic, 0, 0, 0, "")
 }
 
 // Quality is Provided
 
 func TestRectangleWithQuality(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "300x400-q10.jpg")
+	ic, _ := NameToConfiguration(sc, "", "300x400-q10.jpg")
 	ensureImageConfiguration(t, ic, 300, 400, 10, "jpg")
 }
 
 func TestSquareWithQuality(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "x300-q10.jpg")
+	ic, _ := NameToConfiguration(sc, "", "x300-q10.jpg")
 	ensureImageConfiguration(t, ic, 300, 300, 10, "jpg")
 }
 
 func TestWidthWithQuality(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "w300-q10.jpg")
+	ic, _ := NameToConfiguration(sc, "", "w300-q10.jpg")
 	ensureImageConfiguration(t, ic, 300, 0, 10, "jpg")
 }
 
 func TestFullSizeWithQuality(t *testing.T) {
-	ic, _ := NameToConfiguration(sc, "full_size-q10.jpg")
+	ic, _ := NameToConfiguration(sc, "", "full_size-q10.jpg")
 	ensureImageConfiguration(t, ic, 0, 0, 10, "jpg")
 }
+
+// Resize modes
+
+func TestRectangleWithFit(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "300x200-fit.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 200, 75, "jpg")
+	Equals(t, core.ResizeModeFit, ic.Mode)
+}
+
+func TestRectangleWithPad(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "300x200-q10-pad.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 200, 10, "jpg")
+	Equals(t, core.ResizeModePad, ic.Mode)
+	Equals(t, core.GravityCenter, ic.ResizeGravity())
+}
+
+func TestRectangleWithCropGravity(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "300x200-crop_north.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 200, 75, "jpg")
+	Equals(t, core.ResizeModeCrop, ic.Mode)
+	Equals(t, core.GravityNorth, ic.Gravity)
+}
+
+func TestSquareWithNoUpscale(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "x300-noupscale.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 75, "jpg")
+	Equals(t, true, ic.NoUpscale)
+}
+
+func TestWidthWithResizeMode(t *testing.T) {
+	_, err := NameToConfiguration(sc, "", "w300-pad.jpg")
+	Assert(t, core.IsPolicyError(err), "expected a policy error for a resize mode without height")
+}
+
+func TestUnknownOptionIsCustomFile(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "x300-crop_up.jpg")
+	Ok(t, err)
+	Equals(t, "x300-crop_up.jpg", ic.Filename)
+	Equals(t, 0, ic.Width)
+}
+
+func TestSquareWithSmartCrop(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "x300-smart.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 75, "jpg")
+	Equals(t, core.GravitySmart, ic.Gravity)
+}
+
+func TestMaximumDimensions(t *testing.T) {
+	limited := &core.ServerConfiguration{DefaultQuality: 75, MaximumWidth: 1000, MaximumHeight: 1000}
+
+	_, err := NameToConfiguration(limited, "", "99999x99999.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+
+	_, err = NameToConfiguration(limited, "", "w1001.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+
+	_, err = NameToConfiguration(limited, "", "full_size.jpg")
+	Ok(t, err)
+}
+
+func TestAvifWithSpeed(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "w300-q50-s6.avif")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 0, 50, "avif")
+	Equals(t, 6, ic.Speed)
+}
+
+func TestJxlWithEffort(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "300x200-e7.jxl")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 200, 75, "jxl")
+	Equals(t, 7, ic.Effort)
+}
+
+func TestEncoderOptionsForOtherFormats(t *testing.T) {
+	_, err := NameToConfiguration(sc, "", "w300-s6.jpg")
+	Assert(t, core.IsPolicyError(err), "expected a policy error for speed on a jpg output")
+
+	_, err = NameToConfiguration(sc, "", "w300-e7.avif")
+	Assert(t, core.IsPolicyError(err), "expected a policy error for effort on an avif output")
+}
+
+func TestStillFrame(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "x300-frame0.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 75, "jpg")
+	Equals(t, true, ic.Still)
+}
+
+func TestKeepProfile(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "w300-icc.jpg")
+	Ok(t, err)
+	Equals(t, true, ic.KeepProfile)
+}
+
+func TestMetadataPolicy(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "w300.jpg")
+	Ok(t, err)
+	Equals(t, core.MetadataStrip, ic.Metadata)
+
+	ic, err = NameToConfiguration(sc, "", "w300-meta_nogps.jpg")
+	Ok(t, err)
+	Equals(t, core.MetadataNoGPS, ic.Metadata)
+}
+
+func TestFilters(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "x300-q80-trim-rotate270-flip-gray-bcm10_25-blur3-sharpen1-circle.png")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 80, "png")
+	Equals(t, true, ic.Trim)
+	Equals(t, 270, ic.Rotate)
+	Equals(t, true, ic.Flip)
+	Equals(t, true, ic.Gray)
+	Equals(t, -10, ic.Brightness)
+	Equals(t, 25, ic.Contrast)
+	Equals(t, 3, ic.Blur)
+	Equals(t, 1, ic.Sharpen)
+	Equals(t, true, ic.Circle)
+}
+
+func TestInvalidFilters(t *testing.T) {
+	for _, filename := range []string{
+		"x300-gray-trim.jpg",
+		"x300-gray-gray.jpg",
+		"x300-blur99.jpg",
+		"x300-bc101_0.jpg",
+		"300x200-circle.png",
+	} {
+		_, err := NameToConfiguration(sc, "", filename)
+		Assert(t, core.IsPolicyError(err), "expected a policy error for "+filename)
+	}
+
+	ic, err := NameToConfiguration(sc, "", "x300-rotate45.jpg")
+	Ok(t, err)
+	Equals(t, "x300-rotate45.jpg", ic.Filename)
+	Equals(t, 0, ic.Width)
+}
+
+func TestRegion(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "c10,20,400,300-w200.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 200, 0, 75, "jpg")
+	Equals(t, &core.Region{X: 10, Y: 20, Width: 400, Height: 300}, ic.Region)
+	Equals(t, "c10,20,400,300-w200.jpg", ic.Filename)
+
+	_, err = NameToConfiguration(sc, "", "c10,20,0,300-w200.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+
+	_, err = NameToConfiguration(sc, "", "c99999999999999999999,0,1,1-w200.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+
+	_, err = NameToConfiguration(sc, "", "w99999999999999999999.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+
+	_, err = NameToConfiguration(sc, "", "300x99999999999999999999.jpg")
+	Equals(t, true, core.IsPolicyError(err))
+}
+
+func TestBackground(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "300x200-pad-bg_ff0000.jpg")
+	Ok(t, err)
+	Equals(t, "ff0000", ic.Background)
+
+	ic, err = NameToConfiguration(sc, "", "w300-bg_transparent.png")
+	Ok(t, err)
+	Equals(t, core.BackgroundTransparent, ic.Background)
+
+	_, err = NameToConfiguration(sc, "", "w300-bg_transparent.jpg")
+	Assert(t, core.IsPolicyError(err), "expected a policy error for a transparent jpg")
+}
+
+func TestEncoderOptions(t *testing.T) {
+	ic, err := NameToConfiguration(sc, "", "w1600-progressive-sub444.jpg")
+	Ok(t, err)
+	Equals(t, true, ic.Progressive)
+	Equals(t, core.Sampling444, ic.Sampling)
+
+	ic, err = NameToConfiguration(sc, "", "x64-lossless-m6.webp")
+	Ok(t, err)
+	Equals(t, true, ic.Lossless)
+	Equals(t, 6, ic.Method)
+
+	ic, err = NameToConfiguration(sc, "", "x64-z9-colors32.png")
+	Ok(t, err)
+	Equals(t, 9, ic.Compression)
+	Equals(t, 32, ic.Colors)
+
+	for _, filename := range []string{"x64-lossless.jpg", "x64-sub420.webp", "x64-colors300.png", "x64-z9.jpg"} {
+		_, err = NameToConfiguration(sc, "", filename)
+		Assert(t, core.IsPolicyError(err), "expected a policy error for %s", filename)
+	}
+}
+
+func TestQualityModes(t *testing.T) {
+	sc := &core.ServerConfiguration{DefaultQuality: 75, AutoQualitySSIM: 0.98}
+
+	ic, err := NameToConfiguration(sc, "", "x300-qauto.jpg")
+	Ok(t, err)
+	Equals(t, core.QualityAuto, ic.QualityMode())
+	Equals(t, 0.98, ic.SSIM)
+	Equals(t, uint(0), ic.Quality)
+
+	ic, err = NameToConfiguration(sc, "", "w1200-kb80.avif")
+	Ok(t, err)
+	Equals(t, core.QualitySize, ic.QualityMode())
+	Equals(t, 80, ic.TargetSize)
+	Equals(t, uint(0), ic.Quality)
+
+	for _, filename := range []string{"x300-q80-qauto.jpg", "x300-qauto-kb50.jpg", "x300-qauto.avif", "x300-kb50.png"} {
+		_, err = NameToConfiguration(sc, "", filename)
+		Assert(t, core.IsPolicyError(err), "expected a policy error for %s", filename)
+	}
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	. "github.com/image-server/image-server/test"
+)
+
+func namespacedServerConfiguration() *core.ServerConfiguration {
+	return &core.ServerConfiguration{
+		DefaultQuality: 75,
+		Namespaces: map[string]*core.NamespaceConfiguration{
+			"avatars": {
+				Presets:    map[string]string{"thumb.jpg": "x150-q80.jpg"},
+				Quality:    map[string]uint{"webp": 60},
+				Dimensions: []string{"x300", "w600"},
+			},
+		},
+	}
+}
+
+func TestPreset(t *testing.T) {
+	ic, err := NameToConfiguration(namespacedServerConfiguration(), "avatars", "thumb.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 150, 150, 80, "jpg")
+	Equals(t, "thumb.jpg", ic.Filename)
+	Equals(t, "avatars", ic.Namespace)
+}
+
+func TestNamespaceQuality(t *testing.T) {
+	ic, err := NameToConfiguration(namespacedServerConfiguration(), "avatars", "x300.webp")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 60, "webp")
+
+	ic, err = NameToConfiguration(namespacedServerConfiguration(), "avatars", "x300.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 300, 300, 75, "jpg")
+}
+
+func TestDimensionNotAllowed(t *testing.T) {
+	_, err := NameToConfiguration(namespacedServerConfiguration(), "avatars", "x301.jpg")
+	Assert(t, err != nil, "expected x301 to be rejected")
+
+	_, err = NameToConfiguration(namespacedServerConfiguration(), "avatars", "w600-q90.jpg")
+	Ok(t, err)
+}
+
+func TestNamespaceWithoutConfiguration(t *testing.T) {
+	ic, err := NameToConfiguration(namespacedServerConfiguration(), "products", "x301.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 301, 301, 75, "jpg")
+}
+
+func TestRegionOfPreset(t *testing.T) {
+	ic, err := NameToConfiguration(namespacedServerConfiguration(), "avatars", "c0,0,50,50-thumb.jpg")
+	Ok(t, err)
+	ensureImageConfiguration(t, ic, 150, 150, 80, "jpg")
+	Equals(t, 50, ic.Region.Width)
+	Equals(t, "c0,0,50,50-thumb.jpg", ic.Filename)
+}
+
+func TestNamespaceEncoders(t *testing.T) {
+	sc := namespacedServerConfiguration()
+	sc.Namespaces["icons"] = &core.NamespaceConfiguration{
+		Encoders: map[string]*core.EncoderConfiguration{"webp": {Lossless: true, Method: 6}},
+	}
+
+	ic, err := NameToConfiguration(sc, "icons", "x64-m4.webp")
+	Ok(t, err)
+	Equals(t, true, ic.Lossless)
+	Equals(t, 4, ic.Method)
+
+	ic, err = NameToConfiguration(sc, "icons", "x64.jpg")
+	Ok(t, err)
+	Equals(t, false, ic.Lossless)
+}
@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/image-server/image-server/core"
+)
+
+var reQuality, reCrop, reSpeed, reEffort, reMetadata, reBackground *regexp.Regexp
+var reSampling, reNearLossless, reMethod, reCompression, reColors, reTargetSize *regexp.Regexp
+
+var gravities = map[string]string{
+	"center":    core.GravityCenter,
+	"north":     core.GravityNorth,
+	"south":     core.GravitySouth,
+	"east":      core.GravityEast,
+	"west":      core.GravityWest,
+	"northeast": core.GravityNorthEast,
+	"northwest": core.GravityNorthWest,
+	"southeast": core.GravitySouthEast,
+	"southwest": core.GravitySouthWest,
+}
+
+func init() {
+	reQuality = regexp.MustCompile(`^q([0-9]+)$`)
+	// target size in kilobytes, kb50
+	reTargetSize = regexp.MustCompile(`^kb([1-9][0-9]*)$`)
+	// crop, crop_north, crop_southeast
+	reCrop = regexp.MustCompile(`^(crop|pad)(?:_([a-z]+))?$`)
+	// avif encoder speed s1 to s9, jxl encoder effort e1 to e9
+	reSpeed = regexp.MustCompile(`^s([1-9])$`)
+	reEffort = regexp.MustCompile(`^e([1-9])$`)
+	// meta_strip, meta_credits, meta_nogps
+	reMetadata = regexp.MustCompile(`^meta_(strip|credits|nogps)$`)
+	// bg_transparent, bg_ff0000
+	reBackground = regexp.MustCompile(`^bg_(transparent|[0-9a-f]{6})$`)
+	// jpg chroma subsampling sub444, sub422 or sub420
+	reSampling = regexp.MustCompile(`^sub(444|422|420)$`)
+	// webp near lossless nl1 to nl99 and method m1 to m6, png compression z1 to z9, png and gif colors2 to colors256
+	reNearLossless = regexp.MustCompile(`^nl([1-9][0-9]?)$`)
+	reMethod = regexp.MustCompile(`^m([1-6])$`)
+	reCompression = regexp.MustCompile(`^z([1-9])$`)
+	reColors = regexp.MustCompile(`^colors([0-9]+)$`)
+}
+
+// applyOptions sets the dash separated options (i.e. "-q80-fit-gray") on the image configuration.
+// It returns false when an option is not part of the grammar, and an error when
+// the option is not valid for the requested dimensions
+func applyOptions(ic *core.ImageConfiguration, options string) (bool, error) {
+	if options == "" {
+		return true, nil
+	}
+
+	var filters []string
+	for _, option := range strings.Split(options[1:], "-") {
+		filter, err := applyFilter(ic, option)
+		if err != nil {
+			return true, err
+		}
+		if filter != "" {
+			filters = append(filters, filter)
+			continue
+		}
+
+		if m := reQuality.FindStringSubmatch(option); m != nil {
+			quality, _ := strconv.ParseUint(m[1], 10, 0)
+			ic.Quality = uint(quality)
+		} else if option == "qauto" {
+			ic.AutoQuality = true
+		} else if m := reTargetSize.FindStringSubmatch(option); m != nil {
+			ic.TargetSize, _ = strconv.Atoi(m[1])
+		} else if option == "fit" {
+			ic.Mode = core.ResizeModeFit
+		} else if m := reCrop.FindStringSubmatch(option); m != nil {
+			ic.Mode = m[1]
+			if m[2] != "" {
+				gravity, ok := gravities[m[2]]
+				if !ok {
+					return false, nil
+				}
+				ic.Gravity = gravity
+			}
+		} else if option == "smart" {
+			ic.Mode = core.ResizeModeCrop
+			ic.Gravity = core.GravitySmart
+		} else if option == "noupscale" {
+			ic.NoUpscale = true
+		} else if option == "frame0" {
+			ic.Still = true
+		} else if option == "icc" {
+			ic.KeepProfile = true
+		} else if m := reMetadata.FindStringSubmatch(option); m != nil {
+			ic.Metadata = m[1]
+		} else if m := reBackground.FindStringSubmatch(option); m != nil {
+			ic.Background = m[1]
+		} else if option == "progressive" {
+			ic.Progressive = true
+		} else if option == "lossless" {
+			ic.Lossless = true
+		} else if m := reSampling.FindStringSubmatch(option); m != nil {
+			ic.Sampling = m[1]
+		} else if m := reNearLossless.FindStringSubmatch(option); m != nil {
+			ic.NearLossless, _ = strconv.Atoi(m[1])
+		} else if m := reMethod.FindStringSubmatch(option); m != nil {
+			ic.Method, _ = strconv.Atoi(m[1])
+		} else if m := reCompression.FindStringSubmatch(option); m != nil {
+			ic.Compression, _ = strconv.Atoi(m[1])
+		} else if m := reColors.FindStringSubmatch(option); m != nil {
+			ic.Colors, _ = strconv.Atoi(m[1])
+		} else if m := reSpeed.FindStringSubmatch(option); m != nil {
+			ic.Speed, _ = strconv.Atoi(m[1])
+		} else if m := reEffort.FindStringSubmatch(option); m != nil {
+			ic.Effort, _ = strconv.Atoi(m[1])
+		} else {
+			return false, nil
+		}
+	}
+
+	if err := checkFilterOrder(ic, filters); err != nil {
+		return true, err
+	}
+
+	if ic.Mode != "" && (ic.Width == 0 || ic.Height == 0) {
+		return true, core.NewPolicyError("resize mode %s requires both width and height: %s", ic.Mode, ic.Filename)
+	}
+
+	if ic.Background == core.BackgroundTransparent && !ic.AlphaOutput() {
+		return true, core.NewPolicyError("transparent background requires a format with transparency: %s", ic.Filename)
+	}
+
+	if err := checkQualityMode(ic); err != nil {
+		return true, err
+	}
+
+	if err := ic.EncoderConfiguration.Validate(ic.Format); err != nil {
+		return true, core.NewPolicyError("%s: %s", err, ic.Filename)
+	}
+
+	if ic.Speed > 0 && ic.Format != "avif" {
+		return true, core.NewPolicyError("speed is only supported by avif outputs: %s", ic.Filename)
+	}
+
+	if ic.Effort > 0 && ic.Format != "jxl" {
+		return true, core.NewPolicyError("effort is only supported by jxl outputs: %s", ic.Filename)
+	}
+
+	return true, nil
+}
+
+// autoQualityFormats can be decoded to compare them with their reference, targetSizeFormats are lossy
+var (
+	autoQualityFormats = map[string]bool{"jpg": true, "jpeg": true, "webp": true}
+	targetSizeFormats  = map[string]bool{"jpg": true, "jpeg": true, "webp": true, "avif": true, "jxl": true}
+)
+
+// checkQualityMode ensures a single quality mode is used, with a format that supports it
+func checkQualityMode(ic *core.ImageConfiguration) error {
+	modes := 0
+	for _, set := range []bool{ic.Quality > 0, ic.AutoQuality, ic.TargetSize > 0} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return core.NewPolicyError("only one of quality, qauto and kb can be used: %s", ic.Filename)
+	}
+
+	if ic.AutoQuality && !autoQualityFormats[ic.Format] {
+		return core.NewPolicyError("qauto is only supported by jpg and webp outputs: %s", ic.Filename)
+	}
+
+	if ic.TargetSize > 0 && !targetSizeFormats[ic.Format] {
+		return core.NewPolicyError("kb is only supported by jpg, webp, avif and jxl outputs: %s", ic.Filename)
+	}
+	return nil
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"net/url"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/image-server/image-server/cache"
+	"github.com/image-server/image-server/signature"
 )
 
 // Paths
@@ -47,6 +50,40 @@ func (p *Paths) RemoteImageURL(namespace string, md5 string, imageName string) s
 	return u.String()
 }
 
+// SignedImageURL returns the URL of an image on the image server at serverURL, signed with key.
+// The URL does not expire when expires is zero
+func (p *Paths) SignedImageURL(serverURL string, namespace string, md5 string, imageName string, key string, expires time.Time) (string, error) {
+	return p.signedURL(serverURL, namespace, md5, imageName, imageName, url.Values{}, key, expires)
+}
+
+// SignedProcessURL returns the URL processing the outputs of an image on the image server at serverURL, the list
+// of outputs is signed with key. The URL does not expire when expires is zero
+func (p *Paths) SignedProcessURL(serverURL string, namespace string, md5 string, outputs []string, key string, expires time.Time) (string, error) {
+	list := strings.Join(outputs, ",")
+	return p.signedURL(serverURL, namespace, md5, "process", list, url.Values{"outputs": {list}}, key, expires)
+}
+
+// signedURL returns the URL of name in the directory of an image, with the signature of signed
+func (p *Paths) signedURL(serverURL string, namespace string, md5 string, name string, signed string, qs url.Values, key string, expires time.Time) (string, error) {
+	var expiresAt int64
+	if !expires.IsZero() {
+		expiresAt = expires.Unix()
+	}
+
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = filepath.Join("/", u.Path, p.imagePath(namespace, md5, name))
+
+	qs.Set("sig", signature.Sign(key, namespace, md5, signed, expiresAt))
+	if expiresAt > 0 {
+		qs.Set("expires", strconv.FormatInt(expiresAt, 10))
+	}
+	u.RawQuery = qs.Encode()
+	return u.String(), nil
+}
+
 // RemoteOriginalPath returns local path for original image
 func (p *Paths) RemoteOriginalPath(namespace string, md5 string) string {
 	return filepath.Join(p.RemoteBasePath, p.originalPath(namespace, md5))
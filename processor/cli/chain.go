@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+)
+
+// ChainCommandLine returns the command writing every output with the selected adapter, each output is processed
+// in parentheses on a clone of the original
+func ChainCommandLine(processors []*Processor) ([]string, error) {
+	a := Selected
+	first := processors[0]
+	hinted := true
+	var hintWidth, hintHeight int
+	var outputs []string
+	focalPoints := map[aspectRatio]*info.FocalPoint{}
+
+	for _, p := range processors {
+		p.analyzeSmartCrops(focalPoints)
+		args := p.CommandArgs()
+		if err := a.Check(args); err != nil {
+			return nil, core.NewPolicyError("%s: %s", err, p.ImageConfiguration.Filename)
+		}
+
+		width, height, ok := p.jpegSizeHint()
+		hinted = hinted && ok
+		if width > hintWidth {
+			hintWidth = width
+		}
+		if height > hintHeight {
+			hintHeight = height
+		}
+
+		// the operators end with the destination, settings are reset when the parenthesis is closed
+		_, operators := inOrder(args, p.Source)
+		outputs = append(outputs, "(", "+clone")
+		outputs = append(outputs, a.replace(operators[:len(operators)-1])...)
+		outputs = append(outputs, "-write", p.Destination, "+delete", ")")
+	}
+
+	command := append([]string{}, a.Command...)
+	command = append(command, "-respect-parentheses")
+	if hinted {
+		command = append(command, "-define", fmt.Sprintf("jpeg:size=%dx%d", hintWidth, hintHeight))
+	}
+	command = append(command, first.sourceArgument(first.Source))
+	command = append(command, outputs...)
+	return append(command, "null:"), nil
+}
+
+// Chainable returns true when the output can be written by a chain sharing the decoded original with other outputs
+func (p *Processor) Chainable() bool {
+	return Selected.Chains && !p.animate() && p.ImageConfiguration.QualityMode() == core.QualityFixed
+}
+
+// CreateImages writes the outputs of the same original with one run of the selected adapter.
+// The original is decoded once, and every output is processed on a clone of it
+func CreateImages(processors []*Processor) error {
+	if len(processors) == 0 {
+		return nil
+	}
+	if len(processors) == 1 {
+		return processors[0].CreateImage()
+	}
+
+	var finishes []func(error) error
+	err := func() error {
+		for _, p := range processors {
+			finish, err := p.unfiltered()
+			if err != nil {
+				return err
+			}
+			finishes = append(finishes, finish)
+		}
+
+		command, err := ChainCommandLine(processors)
+		if err != nil {
+			return err
+		}
+
+		tmpDir, err := ioutil.TempDir("", "magick")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		return run(processors[0].context(), Selected, command, tmpDir)
+	}()
+
+	// every output is finished, the temporary files of failed chains are removed
+	for _, finish := range finishes {
+		if ferr := finish(err); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
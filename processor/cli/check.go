@@ -1,14 +1,311 @@
 package cli
 
-import "os/exec"
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
 
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/limits"
+)
+
+// Adapter runs a command line image processor with the arguments of ImageMagick 6 convert
+type Adapter struct {
+	// Name selects the adapter with the --processor flag
+	Name string `json:"name"`
+	// Title is the name of the processor in errors, i.e. "ImageMagick"
+	Title string `json:"-"`
+	// Command converts images, i.e. "gm convert"
+	Command []string `json:"-"`
+	// Identify reads the properties of images with IdentifyFormat, the width, height, format and transparency
+	Identify       []string `json:"-"`
+	IdentifyFormat string   `json:"-"`
+	// VersionCommand prints the version and the delegates of the processor
+	VersionCommand []string `json:"-"`
+	// VersionPrefix tells the processor apart from other processors installed under the same command
+	VersionPrefix string `json:"-"`
+	// SourceFirst reads the source before any operator, ImageMagick 7 applies operators in order
+	SourceFirst bool `json:"-"`
+	// Chains writes several outputs of the same original with parentheses and clones
+	Chains bool `json:"-"`
+	// Replacements of ImageMagick 6 arguments, arguments replaced with nothing are removed
+	Replacements map[string][]string `json:"-"`
+	// Unsupported options and defines, outputs using them are rejected
+	Unsupported map[string]bool `json:"-"`
+	// Limits are the environment variables limiting the resources of the processor
+	Limits limits.Variables `json:"-"`
+
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	// Delegates are the libraries the processor is built with, i.e. "heic"
+	Delegates []string `json:"delegates,omitempty"`
+	// Formats maps the formats listed by the processor to their mode, i.e. "avif": "rw+"
+	Formats map[string]string `json:"-"`
+}
+
+var (
+	ImageMagick7 = &Adapter{
+		Name:           core.ProcessorImageMagick7,
+		Title:          "ImageMagick",
+		Command:        []string{"magick"},
+		Identify:       []string{"magick", "identify"},
+		IdentifyFormat: "%[fx:w]:%[fx:h]:%m:%A",
+		VersionCommand: []string{"magick", "-version"},
+		VersionPrefix:  "ImageMagick 7.",
+		SourceFirst:    true,
+		Chains:         true,
+		Limits:         limits.ImageMagick,
+	}
+	ImageMagick6 = &Adapter{
+		Name:           core.ProcessorImageMagick6,
+		Title:          "ImageMagick",
+		Command:        []string{"convert"},
+		Identify:       []string{"identify"},
+		IdentifyFormat: "%[fx:w]:%[fx:h]:%m:%A",
+		VersionCommand: []string{"convert", "-version"},
+		VersionPrefix:  "ImageMagick 6.",
+		Chains:         true,
+		Limits:         limits.ImageMagick,
+	}
+	GraphicsMagick = &Adapter{
+		Name:           core.ProcessorGraphicsMagick,
+		Title:          "GraphicsMagick",
+		Command:        []string{"gm", "convert"},
+		Identify:       []string{"gm", "identify"},
+		IdentifyFormat: "%w:%h:%m:%A",
+		VersionCommand: []string{"gm", "version"},
+		VersionPrefix:  "GraphicsMagick ",
+		// crops don't keep the page offset, and sampling factors are written as geometries
+		Replacements: map[string][]string{
+			"+repage": nil,
+			"-strip":  {"+profile", "*"},
+			"4:4:4":   {"1x1"},
+			"4:2:2":   {"2x1"},
+			"4:2:0":   {"2x2"},
+		},
+		Unsupported: map[string]bool{
+			"-alpha":                true,
+			"-brightness-contrast":  true,
+			"-layers":               true,
+			"-vignette":             true,
+			"png:compression-level": true,
+			"webp:near-lossless":    true,
+		},
+		Limits: limits.GraphicsMagick,
+	}
+)
+
+// Adapters are probed in order, the first available adapter is used by default
+var Adapters = []*Adapter{ImageMagick7, ImageMagick6, GraphicsMagick}
+
+// Selected is the adapter processing images, ImageMagick 6 when no adapter is available
+var Selected = ImageMagick6
+
+// Available is true when the selected adapter is installed
 var Available bool
 
+// Formats maps the formats listed by the selected adapter to their mode, i.e. "avif": "rw+"
+var Formats map[string]string
+
+// OptionalFormats are output formats that depend on delegates that ImageMagick might be missing
+var OptionalFormats = []string{"avif", "jxl"}
+
+var (
+	reFormatLine *regexp.Regexp
+	reVersion    *regexp.Regexp
+	reFeature    *regexp.Regexp
+)
+
 func init() {
-	Available = true
-	cmd := exec.Command("convert", "--version")
-	err := cmd.Run()
+	// i.e. "     AVIF  HEIC      rw+   AV1 Image File Format (1.12.0)"
+	reFormatLine = regexp.MustCompile(`^\s*([A-Za-z0-9-]+)\*?\s+([A-Za-z0-9-]+)\s+([r-][w-][+-])\s`)
+	// i.e. "Version: ImageMagick 7.1.1-15 Q16-HDRI x86_64" or "GraphicsMagick 1.3.38 2022-03-26 Q16"
+	reVersion = regexp.MustCompile(`((?:Image|Graphics)Magick) ([0-9][^ ]*)`)
+	// i.e. "  JPEG-2000                  yes"
+	reFeature = regexp.MustCompile(`^\s+(\S.*?)\s{2,}yes\b`)
+}
+
+// Probe detects the version, delegates and formats of every adapter, and selects the first available adapter.
+// It runs every processor, it is called once the flags are parsed
+func Probe() {
+	Selected = ImageMagick6
+	for i := len(Adapters) - 1; i >= 0; i-- {
+		a := Adapters[i]
+		a.probe()
+		if a.Available {
+			Selected = a
+		}
+	}
+	useSelected()
+}
+
+// Select uses the adapter with the given name, core.ProcessorImageMagick selects the first available adapter
+func Select(name string) error {
+	if name == core.ProcessorImageMagick {
+		return nil
+	}
+
+	for _, a := range Adapters {
+		if a.Name == name {
+			Selected = a
+			useSelected()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown processor %s", name)
+}
+
+func useSelected() {
+	Available = Selected.Available
+	Formats = Selected.Formats
+	info.IdentifyCommand = Selected.Identify
+	info.IdentifyFormat = Selected.IdentifyFormat
+	info.IdentifyLimits = Selected.Limits
+}
+
+func (a *Adapter) probe() {
+	a.Available = false
+	a.Formats = map[string]string{}
+
+	out, err := exec.Command(a.VersionCommand[0], a.VersionCommand[1:]...).Output()
 	if err != nil {
-		Available = false
+		return
+	}
+
+	version, delegates := ParseVersion(string(out))
+	if !strings.HasPrefix(version, a.VersionPrefix) {
+		return
+	}
+	a.Available = true
+	a.Version = version
+	a.Delegates = delegates
+
+	args := append(append([]string{}, a.Command[1:]...), "-list", "format")
+	out, err = exec.Command(a.Command[0], args...).Output()
+	if err == nil {
+		a.Formats = ParseFormats(string(out))
+	}
+}
+
+// ParseVersion reads the version, i.e. "ImageMagick 7.1.1-15", and the delegates printed by
+// "magick -version", "convert -version" or "gm version"
+func ParseVersion(output string) (string, []string) {
+	var version string
+	delegates := []string{}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := reVersion.FindStringSubmatch(line); m != nil && version == "" {
+			version = m[1] + " " + m[2]
+		}
+
+		if strings.HasPrefix(line, "Delegates") {
+			// ImageMagick lists its delegates on one line
+			if i := strings.Index(line, ":"); i >= 0 {
+				delegates = append(delegates, strings.Fields(line[i+1:])...)
+			}
+		} else if m := reFeature.FindStringSubmatch(line); m != nil {
+			// GraphicsMagick lists the support of every feature
+			delegates = append(delegates, strings.ToLower(m[1]))
+		}
+	}
+	return version, delegates
+}
+
+// ParseFormats reads the output of "convert -list format"
+func ParseFormats(list string) map[string]string {
+	formats := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		m := reFormatLine.FindStringSubmatch(scanner.Text())
+		if m != nil {
+			formats[strings.ToLower(m[1])] = m[3]
+		}
+	}
+	return formats
+}
+
+// Check returns an error when the arguments use an option or a define the adapter doesn't support
+func (a *Adapter) Check(args []string) error {
+	for i, arg := range args {
+		option := arg
+		if i > 0 && args[i-1] == "-define" {
+			option = strings.SplitN(arg, "=", 2)[0]
+		}
+		if a.Unsupported[option] {
+			return fmt.Errorf("%s doesn't support %s", a.Title, option)
+		}
+	}
+	return nil
+}
+
+// CommandLine returns the command and the arguments converting source with the arguments of ImageMagick 6 convert
+func (a *Adapter) CommandLine(args []string, source string) []string {
+	if a.SourceFirst {
+		read, operators := inOrder(args, source)
+		args = append(read, operators...)
+	}
+	return append(append([]string{}, a.Command...), a.replace(args)...)
+}
+
+func (a *Adapter) replace(args []string) []string {
+	replaced := make([]string, 0, len(args))
+	for _, arg := range args {
+		if replacement, ok := a.Replacements[arg]; ok {
+			replaced = append(replaced, replacement...)
+			continue
+		}
+		replaced = append(replaced, arg)
+	}
+	return replaced
+}
+
+// inOrder splits the arguments of ImageMagick 6 convert into the arguments reading the source, and the operators
+// applied in order once it is read. Decoder hints stay before the source, the gravity and background settings
+// move before the first operator
+func inOrder(args []string, source string) ([]string, []string) {
+	var read, settings, operators []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == source || strings.HasPrefix(arg, source+"["):
+			read = append(read, arg)
+		case arg == "-define" && i+1 < len(args) && strings.HasPrefix(args[i+1], "jpeg:size="):
+			read = append(read, arg, args[i+1])
+			i++
+		case (arg == "-gravity" || arg == "-background") && i+1 < len(args):
+			settings = append(settings, arg, args[i+1])
+			i++
+		default:
+			operators = append(operators, arg)
+		}
+	}
+	return read, append(settings, operators...)
+}
+
+// SupportsFormat returns false when an optional format can't be written by the selected adapter,
+// or when the adapter lists the format as read only
+func SupportsFormat(format string) bool {
+	format = strings.ToLower(format)
+	for _, optional := range OptionalFormats {
+		if format == optional {
+			return strings.Contains(Formats[format], "w")
+		}
+	}
+
+	mode, listed := Formats[format]
+	return !listed || strings.Contains(mode, "w")
+}
+
+// OptionalFormatsSupport returns the support of every optional format
+func OptionalFormatsSupport() map[string]bool {
+	support := map[string]bool{}
+	for _, format := range OptionalFormats {
+		support[format] = SupportsFormat(format)
 	}
+	return support
 }
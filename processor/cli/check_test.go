@@ -0,0 +1,108 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/cli"
+	. "github.com/image-server/image-server/test"
+)
+
+const formatList = `   Format  Module    Mode  Description
+-------------------------------------------------------------------------------
+     AVIF  HEIC      rw+   AV1 Image File Format (1.12.0)
+      GIF* GIF       rw+   CompuServe graphics interchange format
+     JPEG* JPEG      rw-   Joint Photographic Experts Group JFIF format (80)
+      JXL  JXL       r--   JPEG XL (ISO/IEC 18181)
+     WEBP* WEBP      rw+   WebP Image Format (libwebp 1.2.4 [020F])
+`
+
+func TestParseFormats(t *testing.T) {
+	formats := cli.ParseFormats(formatList)
+
+	Equals(t, "rw+", formats["avif"])
+	Equals(t, "rw+", formats["gif"])
+	Equals(t, "rw-", formats["jpeg"])
+	Equals(t, "r--", formats["jxl"])
+	Equals(t, 5, len(formats))
+}
+
+func TestSupportsFormat(t *testing.T) {
+	formats := cli.Formats
+	defer func() { cli.Formats = formats }()
+	cli.Formats = cli.ParseFormats(formatList)
+
+	Equals(t, true, cli.SupportsFormat("avif"))
+	Equals(t, false, cli.SupportsFormat("jxl"))
+	Equals(t, true, cli.SupportsFormat("jpg"))
+	Equals(t, map[string]bool{"avif": true, "jxl": false}, cli.OptionalFormatsSupport())
+}
+
+const imageMagickVersion = `Version: ImageMagick 7.1.1-15 Q16-HDRI x86_64 21298 https://imagemagick.org
+Copyright: (C) 1999 ImageMagick Studio LLC
+License: https://imagemagick.org/script/license.php
+Features: Cipher DPC HDRI Modules OpenMP(4.5)
+Delegates (built-in): bzlib fontconfig freetype heic jbig jng jp2 jpeg jxl lcms png tiff webp xml zlib
+Compiler: gcc (12.2)
+`
+
+const graphicsMagickVersion = `GraphicsMagick 1.3.38 2022-03-26 Q16 http://www.GraphicsMagick.org/
+Copyright (C) 2002-2022 GraphicsMagick Group.
+
+Feature Support:
+  Native Thread Safe         yes
+  DPS                        no
+  JPEG                       yes
+  OpenMP                     yes (201511 "4.5")
+  WebP                       yes
+
+Host type: x86_64-pc-linux-gnu
+`
+
+func TestParseVersion(t *testing.T) {
+	version, delegates := cli.ParseVersion(imageMagickVersion)
+	Equals(t, "ImageMagick 7.1.1-15", version)
+	Equals(t, []string{"bzlib", "fontconfig", "freetype", "heic", "jbig", "jng", "jp2", "jpeg", "jxl", "lcms", "png", "tiff", "webp", "xml", "zlib"}, delegates)
+
+	version, delegates = cli.ParseVersion(graphicsMagickVersion)
+	Equals(t, "GraphicsMagick 1.3.38", version)
+	Equals(t, []string{"native thread safe", "jpeg", "openmp", "webp"}, delegates)
+}
+
+func TestCommandLine(t *testing.T) {
+	args := []string{"-strip", "-format", "jpg", "-flatten", "-crop", "10x10+0+0", "+repage", "-sampling-factor", "4:2:0", "original", "w10.jpg"}
+
+	Equals(t, []string{"convert", "-strip", "-format", "jpg", "-flatten", "-crop", "10x10+0+0", "+repage", "-sampling-factor", "4:2:0", "original", "w10.jpg"}, cli.ImageMagick6.CommandLine(args, "original"))
+	Equals(t, []string{"magick", "original", "-strip", "-format", "jpg", "-flatten", "-crop", "10x10+0+0", "+repage", "-sampling-factor", "4:2:0", "w10.jpg"}, cli.ImageMagick7.CommandLine(args, "original"))
+	Equals(t, []string{"gm", "convert", "+profile", "*", "-format", "jpg", "-flatten", "-crop", "10x10+0+0", "-sampling-factor", "2x2", "original", "w10.jpg"}, cli.GraphicsMagick.CommandLine(args, "original"))
+
+	// settings given after the operators they affect are moved before them
+	extent := []string{"-define", "jpeg:size=200x200", "-resize", "100x100", "-extent", "100x100", "-gravity", "center", "-background", "none", "original", "100x100.png"}
+	Equals(t, []string{"magick", "-define", "jpeg:size=200x200", "original", "-gravity", "center", "-background", "none", "-resize", "100x100", "-extent", "100x100", "100x100.png"}, cli.ImageMagick7.CommandLine(extent, "original"))
+
+	// the first page of TIFF originals
+	Equals(t, []string{"magick", "original[0]", "-format", "jpg", "w10.jpg"}, cli.ImageMagick7.CommandLine([]string{"-format", "jpg", "original[0]", "w10.jpg"}, "original"))
+}
+
+func TestCheck(t *testing.T) {
+	vignette := []string{"-format", "png", "-vignette", "0x0+0+0", "original", "circle.png"}
+	compression := []string{"-format", "png", "-define", "png:compression-level=9", "original", "z9.png"}
+
+	Ok(t, cli.ImageMagick6.Check(vignette))
+	Ok(t, cli.ImageMagick7.Check(compression))
+	Assert(t, cli.GraphicsMagick.Check(vignette) != nil, "expected -vignette to be unsupported")
+	Assert(t, cli.GraphicsMagick.Check(compression) != nil, "expected png:compression-level to be unsupported")
+}
+
+func TestSelect(t *testing.T) {
+	selected := cli.Selected
+	defer func() { cli.Select(selected.Name) }()
+
+	Ok(t, cli.Select("graphicsmagick"))
+	Equals(t, cli.GraphicsMagick, cli.Selected)
+	Equals(t, cli.GraphicsMagick.Available, cli.Available)
+	Equals(t, []string{"gm", "identify"}, info.IdentifyCommand)
+	Equals(t, "%w:%h:%m:%A", info.IdentifyFormat)
+
+	Assert(t, cli.Select("vips") != nil, "expected an unknown processor to be an error")
+}
@@ -2,15 +2,20 @@ package cli
 
 import (
 	"container/list"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"math"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github.com/golang/glog"
 	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/exif"
 	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/limits"
+	"github.com/image-server/image-server/processor/smartcrop"
 )
 
 type Processor struct {
@@ -18,6 +23,10 @@ type Processor struct {
 	ImageConfiguration *core.ImageConfiguration
 	Source             string
 	Destination        string
+	// FocalPoint overrides the focal point of the image details, it is calculated for smart crops
+	FocalPoint *info.FocalPoint
+	// Context kills the conversions once it is done, nil is never done
+	Context context.Context
 }
 
 func (p *Processor) CreateImage() error {
@@ -27,15 +36,49 @@ func (p *Processor) CreateImage() error {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	args := p.CommandArgs()
-	cmd := exec.Command("convert", args...)
-	cmd.Env = []string{"TMPDIR=" + tmpDir, "MAGICK_DISK_LIMIT=100000000"}
+	p.analyzeSmartCrop()
 
-	err = cmd.Run()
+	finish, err := p.unfiltered()
 	if err != nil {
-		return fmt.Errorf("ImageMagick failed to process the image: convert %s", strings.Join(args, " "))
+		return err
+	}
+
+	if p.ImageConfiguration.QualityMode() == core.QualityFixed {
+		err = p.convert(p.CommandArgs(), tmpDir)
+	} else {
+		err = p.searchQuality(tmpDir)
+	}
+	return finish(err)
+}
+
+// convert runs the selected adapter with its temporary files in tmpDir
+func (p *Processor) convert(args []string, tmpDir string) error {
+	a := Selected
+	if err := a.Check(args); err != nil {
+		return core.NewPolicyError("%s: %s", err, p.ImageConfiguration.Filename)
+	}
+	return run(p.context(), a, a.CommandLine(args, p.Source), tmpDir)
+}
+
+// context returns the context of the processor, conversions are killed once it is done
+func (p *Processor) context() context.Context {
+	if p.Context == nil {
+		return context.Background()
 	}
+	return p.Context
+}
 
+// run runs the processor within the limits of the processes, it is killed after the timeout or once ctx is done
+func run(ctx context.Context, a *Adapter, command []string, tmpDir string) error {
+	_, err := limits.Run(ctx, a.Limits, command, []string{"TMPDIR=" + tmpDir})
+	switch {
+	case err == limits.ErrTimeout:
+		return core.NewError(core.ErrorProcessorTimeout, "%s timed out processing the image after %s: %s", a.Title, limits.Processes.Timeout, strings.Join(command, " "))
+	case err != nil && ctx.Err() != nil:
+		return fmt.Errorf("%s stopped processing the image: %s", a.Title, ctx.Err())
+	case err != nil:
+		return limits.Failure(err, "%s failed to process the image: %s", a.Title, strings.Join(command, " "))
+	}
 	return nil
 }
 
@@ -46,53 +89,574 @@ func (p *Processor) CommandArgs() []string {
 
 	args := list.New()
 
-	args.PushBack("-strip")
+	if width, height, ok := p.jpegSizeHint(); ok {
+		// libjpeg scales the original down while decoding it
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("jpeg:size=%dx%d", width, height))
+	}
+
+	if p.ImageDetails != nil && p.ImageDetails.Orientation > 1 {
+		// the orientation is lost once the metadata is stripped
+		args.PushBack("-auto-orient")
+	}
+
+	if needsSRGBConversion(p.ImageDetails) {
+		// the embedded profile is the source of the conversion, it is lost once the metadata is stripped
+		if p.ImageDetails.Profile != "" && SRGBProfilePath() != "" {
+			args.PushBack("-profile")
+			args.PushBack(SRGBProfilePath())
+		} else {
+			args.PushBack("-colorspace")
+			args.PushBack("sRGB")
+		}
+	}
+
+	p.pushMetadataArguments(args)
+
+	if ic.KeepProfile && SRGBProfilePath() != "" {
+		args.PushBack("-profile")
+		args.PushBack(SRGBProfilePath())
+	}
 
 	args.PushBack("-format")
 	args.PushBack(ic.Format)
 
-	args.PushBack("-flatten")
+	animate := p.animate()
+	if animate {
+		// sequence operators are applied to every frame after reading the source
+		args.PushBack(source)
+		args.PushBack("-coalesce")
+	} else if !ic.TransparentBackground() {
+		// formats without transparency are flattened on the background
+		args.PushBack("-flatten")
+	}
+
+	p.pushGeometricFilters(args)
 
 	if ic.Height > 0 && ic.Width > 0 {
-		cols := p.ImageDetails.Width
-		rows := p.ImageDetails.Height
+		switch ic.ResizeMode() {
+		case core.ResizeModeFit:
+			args.PushBack("-resize")
+			args.PushBack(p.boxGeometry())
+		case core.ResizeModePad:
+			args.PushBack("-resize")
+			args.PushBack(p.boxGeometry())
+
+			args.PushBack("-extent")
+			args.PushBack(fmt.Sprintf("%dx%d", ic.Width, ic.Height))
+
+			args.PushBack("-gravity")
+			args.PushBack(ic.ResizeGravity())
+		default:
+			p.pushCropArguments(args)
+		}
+	} else if ic.Width > 0 {
+		args.PushBack("-resize")
+		if ic.NoUpscale {
+			args.PushBack(fmt.Sprintf("%d>", ic.Width))
+		} else {
+			args.PushBack(fmt.Sprintf("%d", ic.Width))
+		}
+	}
+
+	if animate {
+		moveSettingBefore(args, "-gravity", "-extent")
+		args.PushBack("+repage")
+	}
+
+	p.pushFilters(args)
+
+	args.PushBack("-background")
+	args.PushBack(ic.BackgroundColor())
+	if animate {
+		// the frames are padded by -extent, the background must be set before it
+		moveSettingBefore(args, "-background", "-extent")
+	}
+
+	args.PushBack("-quality")
+	args.PushBack(fmt.Sprintf("%d", ic.Quality))
+
+	if ic.Speed > 0 {
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("heic:speed=%d", ic.Speed))
+	}
+
+	if ic.Effort > 0 {
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("jxl:effort=%d", ic.Effort))
+	}
+
+	p.pushEncoderArguments(args)
+
+	if animate {
+		args.PushBack("-layers")
+		args.PushBack("Optimize")
+	} else {
+		args.PushBack(p.sourceArgument(source))
+	}
+	args.PushBack(destination)
+
+	return p.convertArgumentsToSlice(args)
+}
+
+// pushEncoderArguments sets the options of the JPEG, WebP and PNG encoders
+func (p *Processor) pushEncoderArguments(args *list.List) {
+	ic := p.ImageConfiguration
+
+	if ic.Progressive {
+		args.PushBack("-interlace")
+		if p.jpegOutput() {
+			args.PushBack("Plane")
+		} else {
+			args.PushBack("PNG")
+		}
+	}
+
+	if ic.Sampling != "" {
+		args.PushBack("-sampling-factor")
+		args.PushBack(strings.Join(strings.Split(ic.Sampling, ""), ":"))
+	}
+
+	if ic.Lossless || ic.NearLossless > 0 {
+		args.PushBack("-define")
+		args.PushBack("webp:lossless=true")
+	}
+
+	if ic.NearLossless > 0 {
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("webp:near-lossless=%d", ic.NearLossless))
+	}
+
+	if ic.Method > 0 {
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("webp:method=%d", ic.Method))
+	}
+
+	if ic.Compression > 0 {
+		args.PushBack("-define")
+		args.PushBack(fmt.Sprintf("png:compression-level=%d", ic.Compression))
+	}
+
+	if ic.Colors > 0 {
+		args.PushBack("-colors")
+		args.PushBack(fmt.Sprintf("%d", ic.Colors))
+	}
+}
+
+// pushMetadataArguments removes the metadata rejected by the metadata policy of the output.
+// ImageMagick can't filter the EXIF profile, it is filtered after processing JPEG outputs, and removed from other formats
+func (p *Processor) pushMetadataArguments(args *list.List) {
+	jpeg := p.jpegOutput()
+
+	switch p.ImageConfiguration.MetadataPolicy() {
+	case core.MetadataCredits:
+		args.PushBack("+profile")
+		if jpeg {
+			args.PushBack("!exif,!iptc,!8bim,!xmp,!icc,*")
+		} else {
+			args.PushBack("!iptc,!8bim,!xmp,!icc,*")
+		}
+	case core.MetadataNoGPS:
+		// XMP might repeat the location of the EXIF profile
+		args.PushBack("+profile")
+		args.PushBack("xmp")
+		if !jpeg {
+			args.PushBack("+profile")
+			args.PushBack("exif")
+		}
+	default:
+		args.PushBack("-strip")
+	}
+}
+
+// filtersMetadata returns true when the metadata policy is applied to the EXIF profile of the output once it
+// is written, only JPEG outputs keep their EXIF profile
+func (p *Processor) filtersMetadata() bool {
+	policy := p.ImageConfiguration.MetadataPolicy()
+	return p.jpegOutput() && (policy == core.MetadataCredits || policy == core.MetadataNoGPS)
+}
+
+// unfiltered replaces the destination of outputs whose metadata is filtered by a temporary file next to it, and
+// returns the function finishing the output once it is written. The temporary file is filtered and renamed to
+// the destination, or removed when the output failed, outputs with the metadata to filter are never served
+func (p *Processor) unfiltered() (func(error) error, error) {
+	if !p.filtersMetadata() {
+		return func(err error) error { return err }, nil
+	}
+
+	destination := p.Destination
+	f, err := ioutil.TempFile(filepath.Dir(destination), "unfiltered-*"+filepath.Ext(destination))
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	p.Destination = f.Name()
+
+	return func(err error) error {
+		p.Destination = destination
+		if err == nil {
+			err = p.filterMetadata(f.Name())
+		}
+		if err == nil {
+			err = os.Rename(f.Name(), destination)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+		}
+		return err
+	}, nil
+}
+
+// filterMetadata applies the metadata policy to the EXIF profile of the JPEG written to path
+func (p *Processor) filterMetadata(path string) error {
+	var err error
+	switch p.ImageConfiguration.MetadataPolicy() {
+	case core.MetadataCredits:
+		err = exif.FilterJPEG(path, exif.KeepCredits)
+	case core.MetadataNoGPS:
+		err = exif.FilterJPEG(path, exif.RemoveGPS)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Can't apply the metadata policy to %s: %s", p.Destination, err)
+	}
+	return nil
+}
+
+func (p *Processor) jpegOutput() bool {
+	format := strings.ToLower(p.ImageConfiguration.Format)
+	return format == "jpg" || format == "jpeg"
+}
+
+// animatedFormats are the output formats that keep the frames of animated images
+var animatedFormats = map[string]bool{"gif": true, "webp": true}
+
+// animate returns true when every frame of an animated image is processed
+func (p *Processor) animate() bool {
+	ic := p.ImageConfiguration
+	return p.ImageDetails != nil && p.ImageDetails.Animated() && !ic.Still && animatedFormats[ic.Format]
+}
+
+// sourceArgument selects the first page of TIFF originals, the composite image of Photoshop documents
+// and the first frame of animated images for still outputs
+func (p *Processor) sourceArgument(source string) string {
+	if p.ImageDetails == nil {
+		return source
+	}
+
+	switch p.ImageDetails.ContentType {
+	case "image/tiff", "image/vnd.adobe.photoshop":
+		return source + "[0]"
+	}
 
-		if ic.Width != cols || ic.Height != rows {
-			w := float64(ic.Width) / float64(cols)
-			h := float64(ic.Height) / float64(rows)
-			scale := math.Max(w, h)
+	if p.ImageDetails.Animated() {
+		return source + "[0]"
+	}
+	return source
+}
+
+// moveSettingBefore moves a setting and its value before an operator, settings given after
+// the source only affect the operators that follow them
+func moveSettingBefore(args *list.List, setting string, operator string) {
+	var s, o *list.Element
+	for e := args.Front(); e != nil; e = e.Next() {
+		switch e.Value {
+		case setting:
+			s = e
+		case operator:
+			o = e
+		}
+	}
+
+	if s == nil || o == nil || s.Next() == nil {
+		return
+	}
+
+	value := s.Next()
+	args.MoveBefore(s, o)
+	args.MoveAfter(value, s)
+}
+
+// pushCropArguments resizes the image to fill the requested dimensions,
+// and crops the overflow anchored on the requested gravity or on the focal point of the image
+func (p *Processor) pushCropArguments(args *list.List) {
+	ic := p.ImageConfiguration
+	if ic.Trim {
+		p.pushTrimmedCropArguments(args)
+		return
+	}
+
+	cols, rows := ic.SourceDimensions(p.ImageDetails.Width, p.ImageDetails.Height)
+	width, height := ic.Width, ic.Height
+	resizedCols, resizedRows := cols, rows
+
+	if ic.Width != cols || ic.Height != rows {
+		w := float64(ic.Width) / float64(cols)
+		h := float64(ic.Height) / float64(rows)
+		scale := math.Max(w, h)
+
+		if ic.NoUpscale && scale > 1 {
+			// Crop the largest area with the requested aspect ratio instead of enlarging the image
+			width = int(float64(ic.Width) / scale)
+			height = int(float64(ic.Height) / scale)
+		} else {
 			c := scale * (float64(cols) + 0.5)
 			c = math.Floor(c + 0.5) // Round
 			r := scale * (float64(rows) + 0.5)
 			r = math.Floor(r + 0.5) // Round
 
-			resizeVal := fmt.Sprintf("%dx%d", int(c), int(r))
+			resizedCols, resizedRows = int(c), int(r)
+			resizeVal := fmt.Sprintf("%dx%d", resizedCols, resizedRows)
 
 			args.PushBack("-resize")
 			args.PushBack(resizeVal)
 		}
+	}
+
+	fp := p.focalPoint()
+	if fp != nil {
+		// The offset positions the crop window around the focal point
+		x := focalOffset(fp.X, resizedCols, width)
+		y := focalOffset(fp.Y, resizedRows, height)
 
 		args.PushBack("-extent")
-		args.PushBack(fmt.Sprintf("%dx%d", ic.Width, ic.Height))
+		args.PushBack(fmt.Sprintf("%dx%d+%d+%d", width, height, x, y))
 
 		args.PushBack("-gravity")
-		args.PushBack("center")
+		args.PushBack(core.GravityNorthWest)
+		return
+	}
 
-	} else if ic.Width > 0 {
-		args.PushBack("-resize")
-		args.PushBack(fmt.Sprintf("%d", ic.Width))
+	gravity := ic.ResizeGravity()
+	if gravity == core.GravitySmart {
+		gravity = core.GravityCenter
 	}
 
-	args.PushBack("-background")
-	args.PushBack("rgba(255,255,255,1)")
+	args.PushBack("-extent")
+	args.PushBack(fmt.Sprintf("%dx%d", width, height))
 
-	args.PushBack("-quality")
-	args.PushBack(fmt.Sprintf("%d", ic.Quality))
+	args.PushBack("-gravity")
+	args.PushBack(gravity)
+}
 
-	args.PushBack(source)
-	args.PushBack(destination)
+// pushTrimmedCropArguments fills the requested dimensions with ImageMagick's geometry,
+// the dimensions of the original are unknown once its borders are trimmed
+func (p *Processor) pushTrimmedCropArguments(args *list.List) {
+	ic := p.ImageConfiguration
+	fill := fmt.Sprintf("%dx%d^", ic.Width, ic.Height)
+	if ic.NoUpscale {
+		fill += ">"
+	}
 
-	return p.convertArgumentsToSlice(args)
+	gravity := ic.ResizeGravity()
+	if gravity == core.GravitySmart {
+		gravity = core.GravityCenter
+	}
+
+	args.PushBack("-resize")
+	args.PushBack(fill)
+
+	args.PushBack("-extent")
+	args.PushBack(fmt.Sprintf("%dx%d", ic.Width, ic.Height))
+
+	args.PushBack("-gravity")
+	args.PushBack(gravity)
+}
+
+// focalPoint returns the point crops are centered on, or nil when the crop uses a gravity.
+// The point is moved into the region, and it is rotated and mirrored with the original
+func (p *Processor) focalPoint() *info.FocalPoint {
+	ic := p.ImageConfiguration
+	if ic.Gravity != "" && ic.Gravity != core.GravitySmart {
+		return nil
+	}
+
+	fp := p.FocalPoint
+	if fp == nil && p.ImageDetails != nil {
+		fp = p.ImageDetails.FocalPoint
+	}
+	if fp == nil {
+		return nil
+	}
+
+	var width, height int
+	if p.ImageDetails != nil {
+		width, height = p.ImageDetails.Width, p.ImageDetails.Height
+	}
+	x, y := ic.OutputPoint(fp.X, fp.Y, width, height)
+	return &info.FocalPoint{X: x, Y: y}
+}
+
+// pushGeometricFilters crops the region and applies the filters that change the geometry of the original, before it is resized
+func (p *Processor) pushGeometricFilters(args *list.List) {
+	ic := p.ImageConfiguration
+
+	if r := ic.Region; r != nil {
+		args.PushBack("-crop")
+		args.PushBack(fmt.Sprintf("%dx%d+%d+%d", r.Width, r.Height, r.X, r.Y))
+		args.PushBack("+repage")
+	}
+
+	if ic.Trim {
+		args.PushBack("-trim")
+		args.PushBack("+repage")
+	}
+
+	if ic.Rotate != 0 {
+		args.PushBack("-rotate")
+		args.PushBack(fmt.Sprintf("%d", ic.Rotate))
+	}
+
+	if ic.Flip {
+		args.PushBack("-flip")
+	}
+
+	if ic.Flop {
+		args.PushBack("-flop")
+	}
+}
+
+// pushFilters applies the filters to the resized image
+func (p *Processor) pushFilters(args *list.List) {
+	ic := p.ImageConfiguration
+
+	if ic.Gray {
+		args.PushBack("-colorspace")
+		args.PushBack("Gray")
+	}
+
+	if ic.Brightness != 0 || ic.Contrast != 0 {
+		args.PushBack("-brightness-contrast")
+		args.PushBack(fmt.Sprintf("%dx%d", ic.Brightness, ic.Contrast))
+	}
+
+	if ic.Blur > 0 {
+		args.PushBack("-blur")
+		args.PushBack(fmt.Sprintf("0x%d", ic.Blur))
+	}
+
+	if ic.Sharpen > 0 {
+		args.PushBack("-sharpen")
+		args.PushBack(fmt.Sprintf("0x%d", ic.Sharpen))
+	}
+
+	if ic.Circle {
+		// an ellipse touching the edges of the square output, the corners are transparent or filled with the background
+		if ic.TransparentBackground() {
+			args.PushBack("-alpha")
+			args.PushBack("set")
+			args.PushBack("-background")
+			args.PushBack("none")
+		} else {
+			args.PushBack("-background")
+			args.PushBack(ic.BackgroundColor())
+		}
+		args.PushBack("-vignette")
+		args.PushBack("0x0+0+0")
+	}
+}
+
+// analyzeSmartCrop calculates the focal point of smart crops from the contents of the image.
+// The stored focal point is used when the image can't be analyzed
+func (p *Processor) analyzeSmartCrop() {
+	p.analyzeSmartCrops(map[aspectRatio]*info.FocalPoint{})
+}
+
+// aspectRatio is the reduced width and height of the window of a smart crop
+type aspectRatio struct {
+	width, height int
+}
+
+// analyzeSmartCrops calculates the focal point of smart crops, focalPoints are the points already calculated
+// for the aspect ratio of other outputs of the same image. The focal point only depends on the aspect ratio
+func (p *Processor) analyzeSmartCrops(focalPoints map[aspectRatio]*info.FocalPoint) {
+	ic := p.ImageConfiguration
+	if ic.Gravity != core.GravitySmart || p.FocalPoint != nil || ic.Width == 0 || ic.Height == 0 || ic.Trim || ic.Region != nil {
+		return
+	}
+
+	// the original is analyzed once it is oriented, before its rotation
+	width, height := ic.Width, ic.Height
+	if ic.Transposed() {
+		width, height = height, width
+	}
+	divisor := gcd(width, height)
+	ratio := aspectRatio{width / divisor, height / divisor}
+	if fp, ok := focalPoints[ratio]; ok {
+		p.FocalPoint = fp
+		return
+	}
+
+	orientation := 0
+	if p.ImageDetails != nil {
+		orientation = p.ImageDetails.Orientation
+	}
+
+	fp, err := smartcrop.FocalPoint(p.Source, orientation, width, height)
+	if err != nil {
+		glog.Infof("Unable to analyze %s for smart crop: %s", p.Source, err)
+	}
+	// images that can't be analyzed are cropped around the center, they are not analyzed again
+	focalPoints[ratio] = fp
+	p.FocalPoint = fp
+}
+
+func gcd(a int, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// focalOffset returns the start of a crop window of the given size centered on focal,
+// without leaving the boundaries of the image
+func focalOffset(focal float64, length int, size int) int {
+	offset := int(math.Floor(focal*float64(length) - float64(size)/2 + 0.5))
+	if offset > length-size {
+		offset = length - size
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+// jpegSizeHint returns the dimensions JPEG originals are decoded to, at least twice the resized original.
+// Regions and trims need every pixel of the original
+func (p *Processor) jpegSizeHint() (int, int, bool) {
+	ic := p.ImageConfiguration
+	id := p.ImageDetails
+	if id == nil || id.ContentType != "image/jpeg" || ic.Width == 0 || ic.Region != nil || ic.Trim {
+		return 0, 0, false
+	}
+
+	cols, rows := ic.SourceDimensions(id.Width, id.Height)
+	if cols == 0 || rows == 0 {
+		return 0, 0, false
+	}
+
+	// libjpeg only scales by 1/2, 1/4 or 1/8
+	scale := ic.Scale(id.Width, id.Height)
+	if scale >= 0.25 {
+		return 0, 0, false
+	}
+
+	width := int(math.Ceil(2 * scale * float64(cols)))
+	height := int(math.Ceil(2 * scale * float64(rows)))
+	// the hint applies to the stored original, before it is oriented and rotated
+	if ic.Transposed() != (id.Orientation >= 5) {
+		width, height = height, width
+	}
+	return width, height, true
+}
+
+// boxGeometry returns the geometry used to fit the image within the requested dimensions
+func (p *Processor) boxGeometry() string {
+	ic := p.ImageConfiguration
+	if ic.NoUpscale {
+		return fmt.Sprintf("%dx%d>", ic.Width, ic.Height)
+	}
+	return fmt.Sprintf("%dx%d", ic.Width, ic.Height)
 }
 
 func (p *Processor) convertArgumentsToSlice(arguments *list.List) []string {
@@ -2,9 +2,12 @@ package cli_test
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/icc"
 	"github.com/image-server/image-server/info"
 	"github.com/image-server/image-server/processor/cli"
 	. "github.com/image-server/image-server/test"
@@ -66,3 +69,398 @@ func TestBlankImage(t *testing.T) {
 	errorMsg := fmt.Sprintf("%s", err)
 	Equals(t, "ImageMagick failed to process the image: convert -strip -format jpg -flatten -resize 600 -background rgba(255,255,255,1) -quality 85 test/images/empty.jpg public/test/00/of/rA/empty.jpg", errorMsg)
 }
+
+func TestImageWithFit(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Mode: core.ResizeModeFit, Filename: "300x200-fit.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "300x200", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-fit.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-fit.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithPadAndNoUpscale(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Mode: core.ResizeModePad, NoUpscale: true, Filename: "300x200-pad-noupscale.jpg"}
+	id := &info.ImageProperties{Width: 100, Height: 100}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "300x200>", "-extent", "300x200", "-gravity", "center", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-pad-noupscale.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-pad-noupscale.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithCropGravity(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Mode: core.ResizeModeCrop, Gravity: core.GravityNorth, Filename: "300x200-crop_north.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "300x300", "-extent", "300x200", "-gravity", "north", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-crop_north.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-crop_north.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithCropAndNoUpscale(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, NoUpscale: true, Filename: "300x200-noupscale.jpg"}
+	id := &info.ImageProperties{Width: 150, Height: 150}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-extent", "150x100", "-gravity", "center", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-noupscale.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-noupscale.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithFocalPoint(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Filename: "300x200.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600, FocalPoint: &info.FocalPoint{X: 0.5, Y: 0.9}}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "300x300", "-extent", "300x200+0+100", "-gravity", "northwest", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithFocalPointAndGravity(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Mode: core.ResizeModeCrop, Gravity: core.GravitySouth, Filename: "300x200-crop_south.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600, FocalPoint: &info.FocalPoint{X: 0.5, Y: 0.1}}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "300x300", "-extent", "300x200", "-gravity", "south", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-crop_south.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-crop_south.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithSmartCrop(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 300, Format: "jpg", Quality: 85, Mode: core.ResizeModeCrop, Gravity: core.GravitySmart, Filename: "x300-smart.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 600}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "600x300", "-extent", "300x300+225+0", "-gravity", "northwest", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "x300-smart.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "x300-smart.jpg", ImageConfiguration: ic, ImageDetails: id, FocalPoint: &info.FocalPoint{X: 0.625, Y: 0.5}}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestAvifImageWithSpeed(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "avif", Quality: 60, Speed: 6, Filename: "w600-q60-s6.avif"}
+
+	expected := []string{"-strip", "-format", "avif", "-resize", "600", "-background", "none", "-quality", "60", "-define", "heic:speed=6", "original", "w600-q60-s6.avif"}
+
+	p := cli.Processor{Source: "original", Destination: "w600-q60-s6.avif", ImageConfiguration: ic}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestJxlImageWithEffort(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jxl", Quality: 80, Effort: 7, Filename: "w600-e7.jxl"}
+
+	expected := []string{"-strip", "-format", "jxl", "-resize", "600", "-background", "none", "-quality", "80", "-define", "jxl:effort=7", "original", "w600-e7.jxl"}
+
+	p := cli.Processor{Source: "original", Destination: "w600-e7.jxl", ImageConfiguration: ic}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestPhotoshopImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Filename: "w600.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/vnd.adobe.photoshop"}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original[0]", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestAnimatedImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "webp", Quality: 75, Filename: "300x200.webp"}
+	id := &info.ImageProperties{Width: 600, Height: 600, ContentType: "image/gif", Frames: 12}
+
+	expected := []string{"-strip", "-format", "webp", "original", "-coalesce", "-resize", "300x300", "-gravity", "center", "-background", "none", "-extent", "300x200", "+repage", "-quality", "75", "-layers", "Optimize", "300x200.webp"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200.webp", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestPaddedAnimatedImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "gif", Quality: 75, Mode: core.ResizeModePad, Background: "ff0000", Filename: "300x200-pad-bg_ff0000.gif"}
+	id := &info.ImageProperties{Width: 600, Height: 600, ContentType: "image/gif", Frames: 12}
+
+	expected := []string{"-strip", "-format", "gif", "original", "-coalesce", "-resize", "300x200", "-gravity", "center", "-background", "#ff0000", "-extent", "300x200", "+repage", "-quality", "75", "-layers", "Optimize", "300x200-pad-bg_ff0000.gif"}
+
+	p := cli.Processor{Source: "original", Destination: "300x200-pad-bg_ff0000.gif", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestStillFrameOfAnimatedImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "gif", Quality: 75, Still: true, Filename: "w600-frame0.gif"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/gif", Frames: 12}
+
+	expected := []string{"-strip", "-format", "gif", "-resize", "600", "-background", "none", "-quality", "75", "original[0]", "w600-frame0.gif"}
+
+	p := cli.Processor{Source: "original", Destination: "w600-frame0.gif", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestAnimatedImageToStillFormat(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 75, Filename: "w600.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/gif", Frames: 12}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "75", "original[0]", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestOrientedImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 300, Format: "jpg", Quality: 85, Filename: "x300.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 800, Orientation: 6}
+
+	expected := []string{"-auto-orient", "-strip", "-format", "jpg", "-flatten", "-resize", "300x400", "-extent", "300x300", "-gravity", "center", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "x300.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "x300.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestCMYKImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Filename: "w600.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/jpeg", Colorspace: info.ColorspaceCMYK}
+
+	expected := []string{"-colorspace", "sRGB", "-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestWideGamutImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Filename: "w600.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/jpeg", Colorspace: info.ColorspaceRGB, Profile: "Display P3"}
+
+	expected := []string{"-profile", cli.SRGBProfilePath(), "-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestSRGBImageWithProfile(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, KeepProfile: true, Filename: "w600-icc.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, ContentType: "image/jpeg", Colorspace: info.ColorspaceRGB, Profile: "sRGB IEC61966-2.1"}
+
+	expected := []string{"-strip", "-profile", cli.SRGBProfilePath(), "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w600-icc.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600-icc.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestSRGBProfilePath(t *testing.T) {
+	profile, err := ioutil.ReadFile(cli.SRGBProfilePath())
+	Ok(t, err)
+	Equals(t, icc.SRGB(), profile)
+
+	// the profile is written again once it is removed
+	Ok(t, os.Remove(cli.SRGBProfilePath()))
+	profile, err = ioutil.ReadFile(cli.SRGBProfilePath())
+	Ok(t, err)
+	Equals(t, icc.SRGB(), profile)
+}
+
+func TestImageWithCreditsMetadata(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Metadata: core.MetadataCredits, Filename: "w600.jpg"}
+
+	expected := []string{"+profile", "!exif,!iptc,!8bim,!xmp,!icc,*", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: "w600.jpg", ImageConfiguration: ic}
+	Equals(t, expected, p.CommandArgs())
+}
+
+// copyConverter is an adapter writing the given file to the destination, the last argument
+func copyConverter(file string) *cli.Adapter {
+	return &cli.Adapter{Title: "ImageMagick", Command: []string{"sh", "-c", `for a; do d="$a"; done; cp "` + file + `" "$d"`, "convert"}}
+}
+
+func TestImageWithoutGPSMetadataIsFilteredBeforeItIsStored(t *testing.T) {
+	selected := cli.Selected
+	defer func() { cli.Selected = selected }()
+
+	dir, err := ioutil.TempDir("", "filter")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Metadata: core.MetadataNoGPS, Filename: "w600-meta_nogps.jpg"}
+	p := cli.Processor{Source: "original", Destination: dir + "/w600-meta_nogps.jpg", ImageConfiguration: ic}
+
+	cli.Selected = copyConverter("../../test/images/a.jpg")
+	Ok(t, p.CreateImage())
+	Equals(t, dir+"/w600-meta_nogps.jpg", p.Destination)
+	files, err := ioutil.ReadDir(dir)
+	Ok(t, err)
+	Equals(t, 1, len(files))
+	Equals(t, "w600-meta_nogps.jpg", files[0].Name())
+	Ok(t, os.Remove(p.Destination))
+
+	// outputs that can't be filtered are removed
+	cli.Selected = copyConverter("../../test/images/empty.jpg")
+	Assert(t, p.CreateImage() != nil, "expected an error filtering an invalid JPEG")
+	files, err = ioutil.ReadDir(dir)
+	Ok(t, err)
+	Equals(t, 0, len(files))
+}
+
+func TestWebpImageWithoutGPSMetadata(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "webp", Quality: 85, Metadata: core.MetadataNoGPS, Filename: "w600-meta_nogps.webp"}
+
+	expected := []string{"+profile", "xmp", "+profile", "exif", "-format", "webp", "-resize", "600", "-background", "none", "-quality", "85", "original", "w600-meta_nogps.webp"}
+
+	p := cli.Processor{Source: "original", Destination: "w600-meta_nogps.webp", ImageConfiguration: ic}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithFilters(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 300, Format: "png", Quality: 85, Rotate: 90, Flop: true, Gray: true, Brightness: 10, Contrast: -20, Sharpen: 2, Circle: true, Filename: "x300-rotate90-flop-gray-bc10_m20-sharpen2-circle.png"}
+	id := &info.ImageProperties{Width: 600, Height: 400}
+
+	expected := []string{"-strip", "-format", "png", "-rotate", "90", "-flop", "-resize", "300x450", "-extent", "300x300", "-gravity", "center", "-colorspace", "Gray", "-brightness-contrast", "10x-20", "-sharpen", "0x2", "-alpha", "set", "-background", "none", "-vignette", "0x0+0+0", "-background", "none", "-quality", "85", "original", "x300-rotate90-flop-gray-bc10_m20-sharpen2-circle.png"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestTrimmedImage(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Trim: true, Blur: 4, Filename: "300x200-trim-blur4.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600, FocalPoint: &info.FocalPoint{X: 0.5, Y: 0.9}}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-trim", "+repage", "-resize", "300x200^", "-extent", "300x200", "-gravity", "center", "-blur", "0x4", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-trim-blur4.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestRotatedImageWithFocalPoint(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Quality: 85, Rotate: 180, Filename: "300x200-rotate180.jpg"}
+	id := &info.ImageProperties{Width: 600, Height: 600, FocalPoint: &info.FocalPoint{X: 0.5, Y: 0.9}}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-rotate", "180", "-resize", "300x300", "-extent", "300x200+0+0", "-gravity", "northwest", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "300x200-rotate180.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithRegion(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 200, Height: 200, Format: "jpg", Quality: 85, Region: &core.Region{X: 10, Y: 20, Width: 400, Height: 300}, Filename: "c10,20,400,300-x200.jpg"}
+	id := &info.ImageProperties{Width: 1000, Height: 1000, FocalPoint: &info.FocalPoint{X: 0.01, Y: 0.5}}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-crop", "400x300+10+20", "+repage", "-resize", "267x200", "-extent", "200x200+0+0", "-gravity", "northwest", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "c10,20,400,300-x200.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestImageWithBackgroundColor(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 300, Height: 200, Format: "png", Quality: 85, Mode: core.ResizeModePad, Background: "000000", Filename: "300x200-pad-bg_000000.png"}
+	id := &info.ImageProperties{Width: 600, Height: 600}
+
+	expected := []string{"-strip", "-format", "png", "-flatten", "-resize", "300x200", "-extent", "300x200", "-gravity", "center", "-background", "#000000", "-quality", "85", "original", "300x200-pad-bg_000000.png"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestTransparentBackgroundOfJPEG(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Background: core.BackgroundTransparent, Filename: "w600.jpg"}
+	id := &info.ImageProperties{Width: 1200, Height: 800, HasAlpha: info.Alpha(true)}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w600.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestProgressiveJPEG(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 600, Format: "jpg", Quality: 85, Filename: "w600-progressive-sub420.jpg"}
+	ic.Progressive = true
+	ic.Sampling = core.Sampling420
+	id := &info.ImageProperties{Width: 1200, Height: 800}
+
+	expected := []string{"-strip", "-format", "jpg", "-flatten", "-resize", "600", "-background", "rgba(255,255,255,1)", "-quality", "85", "-interlace", "Plane", "-sampling-factor", "4:2:0", "original", "w600-progressive-sub420.jpg"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestNearLosslessWebP(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 64, Format: "webp", Quality: 75, Filename: "w64-nl60-m6.webp"}
+	ic.NearLossless = 60
+	ic.Method = 6
+	id := &info.ImageProperties{Width: 128, Height: 128}
+
+	expected := []string{"-strip", "-format", "webp", "-resize", "64", "-background", "none", "-quality", "75", "-define", "webp:lossless=true", "-define", "webp:near-lossless=60", "-define", "webp:method=6", "original", "w64-nl60-m6.webp"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestQuantizedPNG(t *testing.T) {
+	ic := &core.ImageConfiguration{Width: 64, Format: "png", Quality: 75, Filename: "w64-z9-colors64.png"}
+	ic.Compression = 9
+	ic.Colors = 64
+	id := &info.ImageProperties{Width: 128, Height: 128}
+
+	expected := []string{"-strip", "-format", "png", "-resize", "64", "-background", "none", "-quality", "75", "-define", "png:compression-level=9", "-colors", "64", "original", "w64-z9-colors64.png"}
+
+	p := cli.Processor{Source: "original", Destination: ic.Filename, ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+}
+
+func TestJPEGSizeHint(t *testing.T) {
+	id := &info.ImageProperties{Width: 4000, Height: 3000, ContentType: "image/jpeg"}
+	ic := &core.ImageConfiguration{Width: 300, Format: "jpg", Quality: 85, Filename: "w300.jpg"}
+
+	expected := []string{"-define", "jpeg:size=600x450", "-strip", "-format", "jpg", "-flatten", "-resize", "300", "-background", "rgba(255,255,255,1)", "-quality", "85", "original", "w300.jpg"}
+	p := cli.Processor{Source: "original", Destination: "w300.jpg", ImageConfiguration: ic, ImageDetails: id}
+	Equals(t, expected, p.CommandArgs())
+
+	// regions need the full original, and small reductions don't benefit from the hint
+	ic.Region = &core.Region{X: 0, Y: 0, Width: 2000, Height: 1500}
+	Equals(t, "-strip", p.CommandArgs()[0])
+	ic.Region = nil
+	ic.Width = 1200
+	Equals(t, "-strip", p.CommandArgs()[0])
+
+	// the hint applies to the stored original, before its EXIF orientation
+	oriented := &info.ImageProperties{Width: 3000, Height: 4000, ContentType: "image/jpeg", Orientation: 6}
+	ic.Width = 300
+	p.ImageDetails = oriented
+	Equals(t, []string{"-define", "jpeg:size=800x600"}, p.CommandArgs()[:2])
+}
+
+func TestChainCommandLineAnalyzesEveryAspectRatioOnce(t *testing.T) {
+	id := &info.ImageProperties{Width: 64, Height: 48, ContentType: "image/jpeg"}
+	smartCrop := func(width int, height int) *cli.Processor {
+		filename := fmt.Sprintf("%dx%d-smart.jpg", width, height)
+		ic := &core.ImageConfiguration{Width: width, Height: height, Gravity: core.GravitySmart, Format: "jpg", Quality: 85, Filename: filename}
+		return &cli.Processor{Source: "../../test/images/a.jpg", Destination: filename, ImageDetails: id, ImageConfiguration: ic}
+	}
+	processors := []*cli.Processor{smartCrop(30, 20), smartCrop(60, 40), smartCrop(20, 20)}
+
+	_, err := cli.ChainCommandLine(processors)
+	Ok(t, err)
+	Assert(t, processors[0].FocalPoint != nil, "expected the smart crop to be analyzed")
+	Assert(t, processors[0].FocalPoint == processors[1].FocalPoint, "expected crops of the same aspect ratio to share their analysis")
+	Assert(t, processors[0].FocalPoint != processors[2].FocalPoint, "expected crops of other aspect ratios to be analyzed")
+}
+
+func TestChainCommandLine(t *testing.T) {
+	selected := cli.Selected
+	defer func() { cli.Select(selected.Name) }()
+	Ok(t, cli.Select("imagemagick6"))
+
+	id := &info.ImageProperties{Width: 4000, Height: 3000, ContentType: "image/jpeg"}
+	processors := []*cli.Processor{
+		{Source: "original", Destination: "w300.jpg", ImageDetails: id, ImageConfiguration: &core.ImageConfiguration{Width: 300, Format: "jpg", Quality: 85, Filename: "w300.jpg"}},
+		{Source: "original", Destination: "100x100.webp", ImageDetails: id, ImageConfiguration: &core.ImageConfiguration{Width: 100, Height: 100, Format: "webp", Quality: 85, Filename: "100x100.webp"}},
+	}
+
+	expected := []string{"convert", "-respect-parentheses", "-define", "jpeg:size=600x450", "original",
+		"(", "+clone", "-background", "rgba(255,255,255,1)", "-strip", "-format", "jpg", "-flatten", "-resize", "300", "-quality", "85", "-write", "w300.jpg", "+delete", ")",
+		"(", "+clone", "-gravity", "center", "-background", "none", "-strip", "-format", "webp", "-resize", "133x100", "-extent", "100x100", "-quality", "85", "-write", "100x100.webp", "+delete", ")",
+		"null:"}
+	command, err := cli.ChainCommandLine(processors)
+	Ok(t, err)
+	Equals(t, expected, command)
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/image-server/image-server/icc"
+	"github.com/image-server/image-server/info"
+)
+
+var (
+	srgbProfileMutex sync.Mutex
+	srgbProfilePath  string
+)
+
+// SRGBProfilePath returns the path of the compact sRGB profile, it is written to a temporary file on first use,
+// and again once the file is removed, i.e. by tmp cleaners. It returns an empty string when the file can't be written
+func SRGBProfilePath() string {
+	srgbProfileMutex.Lock()
+	defer srgbProfileMutex.Unlock()
+
+	if srgbProfilePath != "" {
+		if _, err := os.Stat(srgbProfilePath); err == nil {
+			return srgbProfilePath
+		}
+	}
+
+	path, err := writeSRGBProfile()
+	if err != nil {
+		glog.Errorf("Can't write the sRGB profile: %s", err)
+		return ""
+	}
+	srgbProfilePath = path
+	return srgbProfilePath
+}
+
+func writeSRGBProfile() (string, error) {
+	f, err := ioutil.TempFile("", "image-server-srgb-*.icc")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(icc.SRGB()); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// needsSRGBConversion returns true for CMYK originals, and for color originals with a profile other than sRGB
+func needsSRGBConversion(id *info.ImageProperties) bool {
+	return id != nil && id.NeedsSRGBConversion()
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/image-server/image-server/processor/ssim"
+)
+
+// Qualities searched by the automatic quality modes, and the quality of the reference rendition of auto quality
+const (
+	minimumQuality   = 10
+	maximumQuality   = 95
+	referenceQuality = 100
+)
+
+// searchQuality binary searches the quality of the output, and sets it on the image configuration.
+// Auto quality uses the lowest quality with an SSIM of at least the threshold against a reference rendition,
+// target size uses the highest quality of an output smaller than the target
+func (p *Processor) searchQuality(tmpDir string) error {
+	ic := p.ImageConfiguration
+	if ic.AutoQuality && p.animate() {
+		return fmt.Errorf("qauto is not supported by animated outputs: %s", ic.Filename)
+	}
+
+	candidates := map[int]string{}
+	defer func() {
+		for _, path := range candidates {
+			os.Remove(path)
+		}
+	}()
+
+	encode := func(quality int) (string, error) {
+		if path, ok := candidates[quality]; ok {
+			return path, nil
+		}
+
+		candidateConfiguration := *ic
+		candidateConfiguration.Quality = uint(quality)
+		candidate := *p
+		candidate.ImageConfiguration = &candidateConfiguration
+		candidate.Destination = candidatePath(p.Destination, quality)
+
+		candidates[quality] = candidate.Destination
+		return candidate.Destination, candidate.convert(candidate.CommandArgs(), tmpDir)
+	}
+
+	var acceptable func(path string) (bool, error)
+	if ic.AutoQuality {
+		reference, err := encode(referenceQuality)
+		if err != nil {
+			return err
+		}
+		acceptable = func(path string) (bool, error) {
+			index, err := ssim.CompareFiles(reference, path)
+			return index >= ic.SSIM, err
+		}
+	} else {
+		acceptable = func(path string) (bool, error) {
+			info, err := os.Stat(path)
+			if err != nil {
+				return false, err
+			}
+			return info.Size() <= int64(ic.TargetSize)*1024, nil
+		}
+	}
+
+	// the SSIM and the size grow with the quality
+	quality := 0
+	low, high := minimumQuality, maximumQuality
+	for low <= high {
+		middle := (low + high) / 2
+		path, err := encode(middle)
+		if err != nil {
+			return err
+		}
+
+		ok, err := acceptable(path)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case ok && ic.AutoQuality:
+			quality, high = middle, middle-1
+		case ok:
+			quality, low = middle, middle+1
+		case ic.AutoQuality:
+			low = middle + 1
+		default:
+			high = middle - 1
+		}
+	}
+
+	if quality == 0 {
+		// no quality is acceptable, the closest one is used
+		quality = minimumQuality
+		if ic.AutoQuality {
+			quality = maximumQuality
+		}
+	}
+
+	path, err := encode(quality)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(path, p.Destination); err != nil {
+		return err
+	}
+	delete(candidates, quality)
+
+	ic.Quality = uint(quality)
+	return nil
+}
+
+// candidatePath returns the path of a candidate rendition next to the destination, keeping its extension
+func candidatePath(destination string, quality int) string {
+	ext := filepath.Ext(destination)
+	return fmt.Sprintf("%s.q%d%s", strings.TrimSuffix(destination, ext), quality, ext)
+}
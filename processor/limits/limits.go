@@ -0,0 +1,173 @@
+// Package limits runs command line processors within a time limit and with bounded resources
+package limits
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/image-server/image-server/core"
+)
+
+// Limits of the processes of command line processors, zero values disable a limit
+type Limits struct {
+	// Timeout kills processes running longer, it also bounds their CPU time
+	Timeout time.Duration
+	// Memory and Disk are the megabytes of the pixel cache kept in memory and on disk
+	Memory int
+	Disk   int
+	// Threads used by every process
+	Threads int
+}
+
+// Processes are the limits of every process, they are replaced once the flags are parsed
+var Processes = Limits{Timeout: time.Minute, Disk: 100}
+
+// Variables are the names of the environment variables limiting the resources of a processor, empty names are not set
+type Variables struct {
+	Memory  string
+	Map     string
+	Disk    string
+	Threads string
+	Time    string
+}
+
+var (
+	ImageMagick    = Variables{"MAGICK_MEMORY_LIMIT", "MAGICK_MAP_LIMIT", "MAGICK_DISK_LIMIT", "MAGICK_THREAD_LIMIT", "MAGICK_TIME_LIMIT"}
+	GraphicsMagick = Variables{"MAGICK_LIMIT_MEMORY", "MAGICK_LIMIT_MAP", "MAGICK_LIMIT_DISK", "OMP_NUM_THREADS", ""}
+)
+
+// ErrTimeout is returned when a process is killed after the timeout
+var ErrTimeout = errors.New("killed after the time limit")
+
+// Env returns the environment variables applying the limits with the variables of a processor
+func (l Limits) Env(v Variables) []string {
+	var env []string
+	set := func(name string, value string) {
+		if name != "" {
+			env = append(env, name+"="+value)
+		}
+	}
+
+	if l.Memory > 0 {
+		set(v.Memory, fmt.Sprintf("%dMB", l.Memory))
+		set(v.Map, fmt.Sprintf("%dMB", l.Memory))
+	}
+	if l.Disk > 0 {
+		set(v.Disk, fmt.Sprintf("%dMB", l.Disk))
+	}
+	if l.Threads > 0 {
+		set(v.Threads, fmt.Sprintf("%d", l.Threads))
+	}
+	if l.Timeout > 0 {
+		set(v.Time, fmt.Sprintf("%d", int(l.Timeout/time.Second)))
+	}
+	return env
+}
+
+// cpuTime returns the CPU time of a process using every thread during the timeout
+func (l Limits) cpuTime() time.Duration {
+	threads := l.Threads
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+	return l.Timeout * time.Duration(threads)
+}
+
+// Run runs a command with env and the limits of the processes, and returns its output. The process is killed
+// when ctx is done or after the timeout, the error is ErrTimeout or the error of ctx. The error output of failed
+// processes is kept in the Stderr of the *exec.ExitError
+func Run(ctx context.Context, v Variables, command []string, env []string) ([]byte, error) {
+	l := Processes
+	if l.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.Timeout)
+		defer cancel()
+	}
+
+	command, limited, err := limitCommand(command, l)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(append([]string{}, env...), l.Env(v)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	// without prlimit the process runs unbounded until its limits are set, it is killed when they can't be
+	if !limited {
+		if err := setRlimits(cmd.Process.Pid, l); err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil, fmt.Errorf("unable to limit the resources of %s: %s", command[0], err)
+		}
+	}
+
+	err = cmd.Wait()
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return nil, ErrTimeout
+	case nil:
+		if ee, ok := err.(*exec.ExitError); ok {
+			ee.Stderr = stderr.Bytes()
+		}
+		return stdout.Bytes(), err
+	}
+	return nil, ctx.Err()
+}
+
+// maxDetail is the number of bytes of the error output kept in the detail of failures
+const maxDetail = 1024
+
+// failureCodes match the error output of the processors to the code of the failure, the default is corrupt input
+var failureCodes = []struct {
+	code     string
+	messages []string
+}{
+	{core.ErrorInternal, []string{"prlimit: "}},
+	{core.ErrorProcessorTimeout, []string{"time limit exceeded"}},
+	{core.ErrorUnsupportedFormat, []string{"no decode delegate", "no encode delegate", "nodecodedelegate", "noencodedelegate", "unable to load module"}},
+	{core.ErrorPolicyViolation, []string{"resource limit", "resourcelimit", "resources exhausted", "memory allocation failed", "exceeds limit"}},
+}
+
+// Failure returns the error of a process failed by Run with a formatted message. Processes that exited are
+// classified by their error output, kept in the detail, or by the signal killing them at their limits
+func Failure(err error, format string, a ...interface{}) error {
+	ee, ok := err.(*exec.ExitError)
+	if !ok {
+		return fmt.Errorf(format, a...)
+	}
+
+	detail := strings.TrimSpace(string(ee.Stderr))
+	if len(detail) > maxDetail {
+		detail = detail[:maxDetail]
+	}
+	failure := core.NewError(failureCode(ee, strings.ToLower(detail)), format, a...)
+	failure.Detail = detail
+	return failure
+}
+
+func failureCode(ee *exec.ExitError, stderr string) string {
+	if code := signalCode(ee); code != "" {
+		return code
+	}
+	for _, f := range failureCodes {
+		for _, m := range f.messages {
+			if strings.Contains(stderr, m) {
+				return f.code
+			}
+		}
+	}
+	return core.ErrorCorruptInput
+}
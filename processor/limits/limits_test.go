@@ -0,0 +1,76 @@
+package limits_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/processor/limits"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestEnv(t *testing.T) {
+	l := limits.Limits{Timeout: time.Minute, Memory: 256, Disk: 100, Threads: 2}
+
+	Equals(t, []string{"MAGICK_MEMORY_LIMIT=256MB", "MAGICK_MAP_LIMIT=256MB", "MAGICK_DISK_LIMIT=100MB", "MAGICK_THREAD_LIMIT=2", "MAGICK_TIME_LIMIT=60"}, l.Env(limits.ImageMagick))
+	Equals(t, []string{"MAGICK_LIMIT_MEMORY=256MB", "MAGICK_LIMIT_MAP=256MB", "MAGICK_LIMIT_DISK=100MB", "OMP_NUM_THREADS=2"}, l.Env(limits.GraphicsMagick))
+	Equals(t, []string(nil), limits.Limits{}.Env(limits.ImageMagick))
+}
+
+func TestRun(t *testing.T) {
+	out, err := limits.Run(context.Background(), limits.ImageMagick, []string{"echo", "converted"}, nil)
+	Ok(t, err)
+	Equals(t, "converted\n", string(out))
+}
+
+func TestRunKillsAfterTheTimeout(t *testing.T) {
+	defer func(l limits.Limits) { limits.Processes = l }(limits.Processes)
+	limits.Processes.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := limits.Run(context.Background(), limits.ImageMagick, []string{"sleep", "5"}, nil)
+	Equals(t, limits.ErrTimeout, err)
+	Assert(t, time.Since(start) < time.Second, "expected sleep to be killed, it ran %s", time.Since(start))
+}
+
+func TestRunKillsOnceTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := limits.Run(ctx, limits.ImageMagick, []string{"sleep", "5"}, nil)
+	Equals(t, context.Canceled, err)
+}
+
+func TestFailure(t *testing.T) {
+	fail := func(stderr string) error {
+		_, err := limits.Run(context.Background(), limits.ImageMagick, []string{"sh", "-c", "echo '" + stderr + "' >&2; exit 1"}, nil)
+		return limits.Failure(err, "convert failed")
+	}
+
+	err := fail("convert: no decode delegate for this image format XYZ")
+	Equals(t, core.ErrorUnsupportedFormat, core.ErrorCode(err))
+	Equals(t, "convert failed", err.Error())
+	Equals(t, "convert: no decode delegate for this image format XYZ", err.(*core.Error).Detail)
+
+	Equals(t, core.ErrorPolicyViolation, core.ErrorCode(fail("convert: cache resources exhausted")))
+	Equals(t, core.ErrorProcessorTimeout, core.ErrorCode(fail("convert: time limit exceeded")))
+	Equals(t, core.ErrorCorruptInput, core.ErrorCode(fail("convert: improper image header")))
+
+	_, err = limits.Run(context.Background(), limits.ImageMagick, []string{"missing-command"}, nil)
+	Equals(t, core.ErrorInternal, core.ErrorCode(limits.Failure(err, "convert failed")))
+}
+
+func TestRunLimitsTheResourcesOfTheProcess(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("rlimits are only supported on Linux")
+	}
+	defer func(l limits.Limits) { limits.Processes = l }(limits.Processes)
+	limits.Processes = limits.Limits{Timeout: 2 * time.Second, Threads: 1, Disk: 1}
+
+	// the limits apply from the start of the process, ulimit prints the file size in blocks of 512 bytes
+	out, err := limits.Run(context.Background(), limits.ImageMagick, []string{"sh", "-c", "ulimit -t; ulimit -f"}, nil)
+	Ok(t, err)
+	Equals(t, "2\n2048\n", string(out))
+}
@@ -0,0 +1,94 @@
+package limits
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/image-server/image-server/core"
+)
+
+// prlimitPath is the prlimit of util-linux, it applies the rlimits before the command is executed
+var prlimitPath, _ = exec.LookPath("prlimit")
+
+type rlimit struct {
+	resource int
+	option   string
+	value    uint64
+}
+
+// rlimits bound the CPU time, the data segment (twice the memory limit) and the size of the files written
+func (l Limits) rlimits() []rlimit {
+	var r []rlimit
+	if l.Timeout > 0 {
+		r = append(r, rlimit{syscall.RLIMIT_CPU, "--cpu", uint64((l.cpuTime() + time.Second - 1) / time.Second)})
+	}
+	if l.Memory > 0 {
+		r = append(r, rlimit{syscall.RLIMIT_DATA, "--data", 2 * uint64(l.Memory) << 20})
+	}
+	if l.Disk > 0 {
+		r = append(r, rlimit{syscall.RLIMIT_FSIZE, "--fsize", uint64(l.Disk) << 20})
+	}
+	return r
+}
+
+// limitCommand returns the command executed by prlimit with the rlimits, and whether they are applied before
+// it runs. Without prlimit the rlimits are set once the process started
+func limitCommand(command []string, l Limits) ([]string, bool, error) {
+	r := l.rlimits()
+	if len(r) == 0 {
+		return command, true, nil
+	}
+	if prlimitPath == "" {
+		return command, false, nil
+	}
+
+	// prlimit fails like the processor when the command is missing, it is looked up first
+	path, err := exec.LookPath(command[0])
+	if err != nil {
+		return nil, false, err
+	}
+	limited := []string{prlimitPath}
+	for _, rl := range r {
+		limited = append(limited, fmt.Sprintf("%s=%d", rl.option, rl.value))
+	}
+	limited = append(append(limited, path), command[1:]...)
+	return limited, true, nil
+}
+
+// setRlimits bounds the resources of a running process
+func setRlimits(pid int, l Limits) error {
+	for _, rl := range l.rlimits() {
+		if err := prlimit(pid, rl.resource, rl.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func prlimit(pid int, resource int, limit uint64) error {
+	rlimit := syscall.Rlimit{Cur: limit, Max: limit}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// signalCode returns the code of processes killed once they exceed their CPU time or file size limit
+func signalCode(ee *exec.ExitError) string {
+	status, ok := ee.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	switch status.Signal() {
+	case syscall.SIGXCPU:
+		return core.ErrorProcessorTimeout
+	case syscall.SIGXFSZ:
+		return core.ErrorPolicyViolation
+	}
+	return ""
+}
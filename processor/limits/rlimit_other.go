@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package limits
+
+import "os/exec"
+
+// limitCommand returns the command unchanged, rlimits are only supported on Linux
+func limitCommand(command []string, l Limits) ([]string, bool, error) {
+	return command, true, nil
+}
+
+// setRlimits is only supported on Linux, the processes are bounded by the timeout and the environment variables
+func setRlimits(pid int, l Limits) error {
+	return nil
+}
+
+// signalCode returns no code, processes are not killed at their limits
+func signalCode(ee *exec.ExitError) string {
+	return ""
+}
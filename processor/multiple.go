@@ -0,0 +1,135 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	adapter "github.com/image-server/image-server/processor/cli"
+	"github.com/image-server/image-server/processor/native"
+)
+
+// CreateImages creates the outputs of the same original. The Go backend and ImageMagick chains decode
+// the original once for every output they support, the other outputs are created in parallel.
+// Outputs created by another request are awaited, the first error is returned. The outputs claimed by this
+// request share one work, it is stopped once this request and every request waiting for its outputs are gone
+func CreateImages(processors []*Processor) error {
+	var claimed []*Processor
+	var waiting []chan ProcessorResult
+	w := newWork()
+	defer w.cancel()
+
+	processingMutex.Lock()
+	for _, p := range processors {
+		if channels, present := ImageProcessings[p.Destination]; present {
+			// buffered, the other request doesn't wait for this one
+			c := make(chan ProcessorResult, 1)
+			ImageProcessings[p.Destination] = append(channels, c)
+			processingWork[p.Destination].join(p.Context)
+			waiting = append(waiting, c)
+			continue
+		}
+		ImageProcessings[p.Destination] = []chan ProcessorResult{}
+		processingWork[p.Destination] = w
+		w.join(p.Context)
+		claimed = append(claimed, p)
+	}
+	processingMutex.Unlock()
+
+	errs := createAll(w.ctx, claimed)
+
+	var firstErr error
+	for i, p := range claimed {
+		processingMutex.Lock()
+		channels := ImageProcessings[p.Destination]
+		delete(ImageProcessings, p.Destination)
+		delete(processingWork, p.Destination)
+		processingMutex.Unlock()
+
+		for _, c := range channels {
+			c <- ProcessorResult{p.Destination, errs[i]}
+			close(c)
+		}
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+
+	for _, c := range waiting {
+		result := <-c
+		if result.Error != nil && firstErr == nil {
+			firstErr = result.Error
+		}
+	}
+	return firstErr
+}
+
+// createAll creates the missing outputs until ctx is done, and returns the error of every output
+func createAll(ctx context.Context, processors []*Processor) []error {
+	errs := make([]error, len(processors))
+	var natives, chained []int
+	var wg sync.WaitGroup
+
+	// group creates the outputs at indexes with one worker
+	group := func(indexes []int, create func() error) {
+		defer wg.Done()
+		start := time.Now()
+		release, err := scheduler.Acquire(ctx, processors[indexes[0]].Priority)
+		if err != nil {
+			for _, i := range indexes {
+				errs[i] = err
+			}
+			return
+		}
+		err = create()
+		release()
+
+		for _, i := range indexes {
+			p := processors[i]
+			errs[i] = p.created(start, err)
+			if errs[i] == nil {
+				p.notifyProcessed()
+			}
+		}
+	}
+
+	for i, p := range processors {
+		if _, err := os.Stat(p.Destination); !os.IsNotExist(err) {
+			p.notifySkipped()
+			continue
+		}
+		os.MkdirAll(filepath.Dir(p.Destination), 0700)
+
+		if p.nativeProcessor() != nil {
+			natives = append(natives, i)
+		} else if p.adapterProcessor(ctx).Chainable() {
+			chained = append(chained, i)
+		} else {
+			wg.Add(1)
+			go group([]int{i}, p.adapterProcessor(ctx).CreateImage)
+		}
+	}
+
+	if len(natives) > 0 {
+		outputs := make([]*native.Processor, len(natives))
+		for j, i := range natives {
+			outputs[j] = processors[i].nativeProcessor()
+		}
+		wg.Add(1)
+		go group(natives, func() error { return native.CreateImages(outputs) })
+	}
+
+	if len(chained) > 0 {
+		outputs := make([]*adapter.Processor, len(chained))
+		for j, i := range chained {
+			outputs[j] = processors[i].adapterProcessor(ctx)
+		}
+		wg.Add(1)
+		go group(chained, func() error { return adapter.CreateImages(outputs) })
+	}
+
+	wg.Wait()
+	return errs
+}
@@ -0,0 +1,52 @@
+package processor_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestCreateImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "processor")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	source := "../test/images/a.jpg"
+	i := info.Info{Path: source}
+	id, err := i.ImageDetails()
+	Ok(t, err)
+
+	processor.SetScheduler(processor.NewScheduler(1, 0, 0))
+	defer processor.SetScheduler(processor.NewScheduler(0, 0, 0))
+
+	configurations := []*core.ImageConfiguration{
+		{Width: 100, Format: "jpg", Filename: "w100.jpg"},
+		{Width: 100, Height: 100, Format: "png", Filename: "100x100.png"},
+		{Width: 50, Format: "gif", Filename: "w50.gif"},
+	}
+
+	var processors []*processor.Processor
+	for _, ic := range configurations {
+		processors = append(processors, &processor.Processor{
+			Source:             source,
+			Destination:        filepath.Join(dir, "outputs", ic.Filename),
+			ImageConfiguration: ic,
+			ImageDetails:       id,
+			Backend:            core.ProcessorGo,
+		})
+	}
+
+	Ok(t, processor.CreateImages(processors))
+	for _, p := range processors {
+		ExpectFile(t, p.Destination)
+	}
+
+	// existing outputs are skipped
+	Ok(t, processor.CreateImages(processors))
+}
@@ -0,0 +1,452 @@
+// Package native processes images in pure Go, without forking ImageMagick.
+// It resizes, crops and pads JPEG, PNG, GIF, WebP, BMP and TIFF originals into JPEG, PNG and GIF outputs.
+// Supports reports the outputs that need ImageMagick.
+package native
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/smartcrop"
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// sourceTypes are the content types of the originals decoded in Go
+var sourceTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+	"image/tiff": true,
+}
+
+// outputFormats are the formats encoded in Go
+var outputFormats = map[string]bool{"jpg": true, "jpeg": true, "png": true, "gif": true}
+
+type Processor struct {
+	ImageDetails       *info.ImageProperties
+	ImageConfiguration *core.ImageConfiguration
+	Source             string
+	Destination        string
+	// FocalPoint overrides the focal point of the image details
+	FocalPoint *info.FocalPoint
+}
+
+// Supports returns true when the output can be processed without ImageMagick
+func Supports(ic *core.ImageConfiguration, id *info.ImageProperties) bool {
+	switch {
+	case id == nil || !sourceTypes[id.ContentType] || !outputFormats[ic.Format]:
+		return false
+	case id.Animated() && (id.ContentType == "image/webp" || (ic.Format == "gif" && !ic.Still)):
+		// the webp decoder only reads still images, and only the first frame of a GIF is decoded
+		return false
+	case id.NeedsSRGBConversion():
+		return false
+	case ic.MetadataPolicy() != core.MetadataStrip || ic.KeepProfile:
+		return false
+	case ic.QualityMode() != core.QualityFixed:
+		return false
+	case ic.Trim || ic.Blur > 0 || ic.Sharpen > 0 || ic.Brightness != 0 || ic.Contrast != 0 || ic.Circle:
+		return false
+	case ic.Progressive || (ic.Sampling != "" && ic.Sampling != core.Sampling420) || ic.Colors > 0:
+		// the JPEG encoder only writes baseline 4:2:0 images
+		return false
+	}
+	return true
+}
+
+func (p *Processor) CreateImage() error {
+	img, err := decode(p.Source)
+	if err != nil {
+		return err
+	}
+	return p.createFrom(img)
+}
+
+// CreateImages writes the outputs of the same original, it is decoded once
+func CreateImages(processors []*Processor) error {
+	if len(processors) == 0 {
+		return nil
+	}
+
+	img, err := decode(processors[0].Source)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range processors {
+		if err := p.createFrom(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFrom writes the output from the decoded original, the original is not modified
+func (p *Processor) createFrom(img *image.RGBA) error {
+	img = p.transform(img)
+	img = p.resize(img)
+	if p.ImageConfiguration.Gray {
+		img = gray(img)
+	}
+	if !p.ImageConfiguration.TransparentBackground() {
+		img = flatten(img, p.background())
+	}
+
+	if err := p.encode(img); err != nil {
+		return fmt.Errorf("Can't encode %s: %s", p.Destination, err)
+	}
+	return nil
+}
+
+func decode(path string) (*image.RGBA, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	src, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, core.NewError(core.ErrorCorruptInput, "Can't decode %s: %s", path, err)
+	}
+
+	b := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(img, img.Bounds(), src, b.Min, draw.Src)
+	return img, nil
+}
+
+// transform orients the original, crops the region, then rotates and mirrors it
+func (p *Processor) transform(img *image.RGBA) *image.RGBA {
+	ic := p.ImageConfiguration
+
+	if p.ImageDetails != nil && p.ImageDetails.Orientation > 1 {
+		img = orient(img, p.ImageDetails.Orientation)
+	}
+
+	if r := ic.Region; r != nil {
+		img = img.SubImage(image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)).(*image.RGBA)
+	}
+
+	// rotations and mirrors are EXIF orientations
+	switch ic.Rotate {
+	case 90:
+		img = orient(img, 6)
+	case 180:
+		img = orient(img, 3)
+	case 270:
+		img = orient(img, 8)
+	}
+	if ic.Flip {
+		img = orient(img, 4)
+	}
+	if ic.Flop {
+		img = orient(img, 2)
+	}
+	return img
+}
+
+// orient returns the image with an EXIF orientation applied
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// source returns the pixel of the original displayed at x, y
+	var source func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2:
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		source = func(x, y int) (int, int) { return y, x }
+	case 6:
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return img
+	}
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := source(x, y)
+			i := img.PixOffset(b.Min.X+sx, b.Min.Y+sy)
+			copy(dst.Pix[dst.PixOffset(x, y):], img.Pix[i:i+4])
+		}
+	}
+	return dst
+}
+
+// resize scales the image to the requested width, or fits, pads or crops it to the requested dimensions
+func (p *Processor) resize(img *image.RGBA) *image.RGBA {
+	ic := p.ImageConfiguration
+	cols, rows := img.Bounds().Dx(), img.Bounds().Dy()
+
+	switch {
+	case ic.Width > 0 && ic.Height > 0:
+		switch ic.ResizeMode() {
+		case core.ResizeModeFit:
+			return p.fit(img)
+		case core.ResizeModePad:
+			return p.pad(p.fit(img))
+		}
+		return p.crop(img)
+	case ic.Width > 0:
+		if ic.NoUpscale && ic.Width >= cols {
+			return img
+		}
+		height := int(math.Max(1, math.Floor(float64(rows)*float64(ic.Width)/float64(cols)+0.5)))
+		return scale(img, ic.Width, height)
+	}
+	return img
+}
+
+// fit scales the image to fit within the requested dimensions
+func (p *Processor) fit(img *image.RGBA) *image.RGBA {
+	ic := p.ImageConfiguration
+	cols, rows := img.Bounds().Dx(), img.Bounds().Dy()
+
+	s := math.Min(float64(ic.Width)/float64(cols), float64(ic.Height)/float64(rows))
+	if ic.NoUpscale && s >= 1 {
+		return img
+	}
+
+	width := int(math.Max(1, math.Floor(float64(cols)*s+0.5)))
+	height := int(math.Max(1, math.Floor(float64(rows)*s+0.5)))
+	return scale(img, width, height)
+}
+
+// pad extends the image to the requested dimensions, anchored on the requested gravity
+func (p *Processor) pad(img *image.RGBA) *image.RGBA {
+	ic := p.ImageConfiguration
+	b := img.Bounds()
+
+	gx, gy := anchor(ic.ResizeGravity())
+	x := int(math.Floor(gx*float64(ic.Width-b.Dx()) + 0.5))
+	y := int(math.Floor(gy*float64(ic.Height-b.Dy()) + 0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, ic.Width, ic.Height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(p.background()), image.ZP, draw.Src)
+	draw.Draw(dst, image.Rect(x, y, x+b.Dx(), y+b.Dy()), img, b.Min, draw.Src)
+	return dst
+}
+
+// crop scales the image to fill the requested dimensions, and crops the overflow
+// around the focal point of the image or anchored on the requested gravity
+func (p *Processor) crop(img *image.RGBA) *image.RGBA {
+	ic := p.ImageConfiguration
+	cols, rows := img.Bounds().Dx(), img.Bounds().Dy()
+	width, height := ic.Width, ic.Height
+
+	s := math.Max(float64(ic.Width)/float64(cols), float64(ic.Height)/float64(rows))
+	if ic.NoUpscale && s > 1 {
+		// Crop the largest area with the requested aspect ratio instead of enlarging the image
+		width = int(math.Max(1, float64(ic.Width)/s))
+		height = int(math.Max(1, float64(ic.Height)/s))
+	} else if s != 1 {
+		resizedCols := int(math.Max(float64(width), math.Floor(float64(cols)*s+0.5)))
+		resizedRows := int(math.Max(float64(height), math.Floor(float64(rows)*s+0.5)))
+		img = scale(img, resizedCols, resizedRows)
+	}
+
+	b := img.Bounds()
+	var x, y int
+	if fp := p.focalPoint(img); fp != nil {
+		x = focalOffset(fp.X, b.Dx(), width)
+		y = focalOffset(fp.Y, b.Dy(), height)
+	} else {
+		gx, gy := anchor(ic.ResizeGravity())
+		x = int(math.Floor(gx*float64(b.Dx()-width) + 0.5))
+		y = int(math.Floor(gy*float64(b.Dy()-height) + 0.5))
+	}
+
+	r := image.Rect(x, y, x+width, y+height).Add(b.Min)
+	return img.SubImage(r).(*image.RGBA)
+}
+
+// focalPoint returns the point crops are centered on, or nil when the crop uses a gravity.
+// Smart crops analyze the transformed image, stored points are moved into the region, rotated and mirrored
+func (p *Processor) focalPoint(img *image.RGBA) *info.FocalPoint {
+	ic := p.ImageConfiguration
+	if ic.Gravity != "" && ic.Gravity != core.GravitySmart {
+		return nil
+	}
+
+	fp := p.FocalPoint
+	if fp == nil && ic.Gravity == core.GravitySmart {
+		return smartcrop.Analyze(img, ic.Width, ic.Height)
+	}
+	if fp == nil && p.ImageDetails != nil {
+		fp = p.ImageDetails.FocalPoint
+	}
+	if fp == nil {
+		return nil
+	}
+
+	var width, height int
+	if p.ImageDetails != nil {
+		width, height = p.ImageDetails.Width, p.ImageDetails.Height
+	}
+	x, y := ic.OutputPoint(fp.X, fp.Y, width, height)
+	return &info.FocalPoint{X: x, Y: y}
+}
+
+// focalOffset returns the start of a crop window of the given size centered on focal,
+// without leaving the boundaries of the image
+func focalOffset(focal float64, length int, size int) int {
+	offset := int(math.Floor(focal*float64(length) - float64(size)/2 + 0.5))
+	if offset > length-size {
+		offset = length - size
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return offset
+}
+
+// anchor returns the position of a gravity, as fractions of the width and height
+func anchor(gravity string) (float64, float64) {
+	switch gravity {
+	case core.GravityNorth:
+		return 0.5, 0
+	case core.GravitySouth:
+		return 0.5, 1
+	case core.GravityEast:
+		return 1, 0.5
+	case core.GravityWest:
+		return 0, 0.5
+	case core.GravityNorthEast:
+		return 1, 0
+	case core.GravityNorthWest:
+		return 0, 0
+	case core.GravitySouthEast:
+		return 1, 1
+	case core.GravitySouthWest:
+		return 0, 1
+	}
+	return 0.5, 0.5
+}
+
+func scale(img *image.RGBA, width int, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), nil)
+	return dst
+}
+
+// gray returns the image with its colors replaced by their luminance
+func gray(img *image.RGBA) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			pix := img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):]
+			l := uint8((299*uint32(pix[0]) + 587*uint32(pix[1]) + 114*uint32(pix[2]) + 500) / 1000)
+			copy(dst.Pix[dst.PixOffset(x, y):], []uint8{l, l, l, pix[3]})
+		}
+	}
+	return dst
+}
+
+// flatten composes the image over the background
+func flatten(img *image.RGBA, background color.Color) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.ZP, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// background returns the color of padded and transparent areas, outputs without a background are white
+// unless they keep transparency
+func (p *Processor) background() color.Color {
+	ic := p.ImageConfiguration
+	if ic.TransparentBackground() {
+		return color.Transparent
+	}
+
+	rgb, err := strconv.ParseUint(ic.Background, 16, 32)
+	if err != nil {
+		return color.White
+	}
+	return color.RGBA{uint8(rgb >> 16), uint8(rgb >> 8), uint8(rgb), 0xff}
+}
+
+func (p *Processor) encode(img *image.RGBA) error {
+	ic := p.ImageConfiguration
+	writer, err := os.Create(p.Destination)
+	if err != nil {
+		return err
+	}
+
+	switch ic.Format {
+	case "png":
+		encoder := png.Encoder{CompressionLevel: compressionLevel(ic.Compression)}
+		err = encoder.Encode(writer, img)
+	case "gif":
+		err = gif.Encode(writer, paletted(img, ic.TransparentBackground()), nil)
+	default:
+		quality := int(ic.Quality)
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(writer, img, &jpeg.Options{Quality: quality})
+	}
+
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(p.Destination)
+	}
+	return err
+}
+
+// compressionLevel maps the compression levels of ImageMagick to the levels of the PNG encoder
+func compressionLevel(compression int) png.CompressionLevel {
+	switch {
+	case compression == 0:
+		return png.DefaultCompression
+	case compression <= 3:
+		return png.BestSpeed
+	case compression >= 7:
+		return png.BestCompression
+	}
+	return png.DefaultCompression
+}
+
+// paletted dithers the image to the Plan 9 palette, with a transparent entry when transparency is kept
+func paletted(img *image.RGBA, transparent bool) *image.Paletted {
+	p := color.Palette(palette.Plan9)
+	if transparent {
+		p = append(color.Palette{color.Transparent}, p[:255]...)
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()), p)
+	draw.FloydSteinberg.Draw(dst, dst.Bounds(), img, img.Bounds().Min)
+	return dst
+}
@@ -0,0 +1,188 @@
+package native_test
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/native"
+	. "github.com/image-server/image-server/test"
+)
+
+func imageDetails(t *testing.T, path string) *info.ImageProperties {
+	i := info.Info{Path: path}
+	id, err := i.ImageDetails()
+	Ok(t, err)
+	return id
+}
+
+func TestSupports(t *testing.T) {
+	jpeg := &info.ImageProperties{Width: 574, Height: 496, ContentType: "image/jpeg"}
+	animated := &info.ImageProperties{Width: 10, Height: 10, ContentType: "image/gif", Frames: 3}
+	cmyk := &info.ImageProperties{Width: 10, Height: 10, ContentType: "image/jpeg", Colorspace: info.ColorspaceCMYK}
+	psd := &info.ImageProperties{Width: 10, Height: 10, ContentType: "image/vnd.adobe.photoshop"}
+
+	tests := []struct {
+		ic       *core.ImageConfiguration
+		id       *info.ImageProperties
+		expected bool
+	}{
+		{&core.ImageConfiguration{Width: 100, Format: "jpg"}, jpeg, true},
+		{&core.ImageConfiguration{Width: 100, Height: 100, Format: "png", Rotate: 90, Gray: true}, jpeg, true},
+		{&core.ImageConfiguration{Width: 100, Format: "gif", Still: true}, animated, true},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg"}, animated, true},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg"}, nil, false},
+		{&core.ImageConfiguration{Width: 100, Format: "webp"}, jpeg, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg"}, psd, false},
+		{&core.ImageConfiguration{Width: 100, Format: "gif"}, animated, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg"}, cmyk, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg", Metadata: core.MetadataCredits}, jpeg, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg", AutoQuality: true}, jpeg, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg", Blur: 2}, jpeg, false},
+		{&core.ImageConfiguration{Width: 100, Format: "jpg", EncoderConfiguration: core.EncoderConfiguration{Progressive: true}}, jpeg, false},
+	}
+
+	for _, test := range tests {
+		Assert(t, native.Supports(test.ic, test.id) == test.expected, "expected %v for %+v", test.expected, test.ic)
+	}
+}
+
+func TestCreateImage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		source string
+		ic     *core.ImageConfiguration
+		width  int
+		height int
+	}{
+		{"a.jpg", &core.ImageConfiguration{Width: 100, Format: "jpg", Quality: 75}, 100, 86},
+		{"a.jpg", &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg"}, 300, 200},
+		{"a.jpg", &core.ImageConfiguration{Width: 300, Height: 200, Format: "jpg", Gravity: core.GravitySmart}, 300, 200},
+		{"a.jpg", &core.ImageConfiguration{Width: 1000, Height: 500, Format: "jpg", NoUpscale: true}, 574, 287},
+		{"a.jpg", &core.ImageConfiguration{Width: 200, Height: 200, Format: "png", Mode: core.ResizeModeFit}, 200, 173},
+		{"a.jpg", &core.ImageConfiguration{Width: 200, Height: 200, Format: "png", Mode: core.ResizeModePad}, 200, 200},
+		{"a.jpg", &core.ImageConfiguration{Width: 100, Format: "gif", Rotate: 90}, 100, 116},
+		{"a.jpg", &core.ImageConfiguration{Width: 50, Format: "jpg", Region: &core.Region{X: 10, Y: 10, Width: 100, Height: 200}}, 50, 100},
+		{"orientation_6.jpg", &core.ImageConfiguration{Width: 24, Format: "png"}, 24, 32},
+		{"a.webp", &core.ImageConfiguration{Width: 100, Height: 100, Format: "jpg", Gray: true}, 100, 100},
+	}
+
+	for i, test := range tests {
+		source := filepath.Join("../../test/images", test.source)
+		destination := filepath.Join(dir, fmt.Sprintf("%d.%s", i, test.ic.Format))
+
+		p := &native.Processor{
+			Source:             source,
+			Destination:        destination,
+			ImageConfiguration: test.ic,
+			ImageDetails:       imageDetails(t, source),
+		}
+		Ok(t, p.CreateImage())
+
+		reader, err := os.Open(destination)
+		Ok(t, err)
+		config, _, err := image.DecodeConfig(reader)
+		reader.Close()
+		Ok(t, err)
+
+		Equals(t, test.width, config.Width)
+		Equals(t, test.height, config.Height)
+	}
+}
+
+func TestCreateImageKeepsTransparency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	source := "../../test/images/transparent.png"
+	tests := []struct {
+		ic    *core.ImageConfiguration
+		alpha uint8
+	}{
+		{&core.ImageConfiguration{Width: 64, Height: 32, Format: "png", Mode: core.ResizeModePad}, 0},
+		{&core.ImageConfiguration{Width: 64, Height: 32, Format: "png", Mode: core.ResizeModePad, Background: "ff0000"}, 0xff},
+	}
+
+	for _, test := range tests {
+		destination := filepath.Join(dir, "padded.png")
+		p := &native.Processor{
+			Source:             source,
+			Destination:        destination,
+			ImageConfiguration: test.ic,
+			ImageDetails:       imageDetails(t, source),
+		}
+		Ok(t, p.CreateImage())
+
+		reader, err := os.Open(destination)
+		Ok(t, err)
+		img, err := png.Decode(reader)
+		reader.Close()
+		Ok(t, err)
+
+		// the padding on the left of the image
+		c := color.NRGBAModel.Convert(img.At(0, 16)).(color.NRGBA)
+		Equals(t, test.alpha, c.A)
+		os.Remove(destination)
+	}
+}
+
+func TestCreateImages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	source := "../../test/images/a.jpg"
+	id := imageDetails(t, source)
+	configurations := []*core.ImageConfiguration{
+		{Width: 100, Format: "png", Gray: true},
+		{Width: 100, Format: "png"},
+	}
+
+	var processors []*native.Processor
+	for i, ic := range configurations {
+		processors = append(processors, &native.Processor{
+			Source:             source,
+			Destination:        filepath.Join(dir, fmt.Sprintf("%d.png", i)),
+			ImageConfiguration: ic,
+			ImageDetails:       id,
+		})
+	}
+	Ok(t, native.CreateImages(processors))
+
+	// the gray output doesn't change the original shared with the color output
+	colors := 0
+	for _, p := range processors {
+		reader, err := os.Open(p.Destination)
+		Ok(t, err)
+		img, err := png.Decode(reader)
+		reader.Close()
+		Ok(t, err)
+
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				if r != g || g != b {
+					colors++
+				}
+			}
+		}
+		if p == processors[0] {
+			Equals(t, 0, colors)
+		}
+	}
+	Assert(t, colors > 0, "expected the second output to have colors")
+}
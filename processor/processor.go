@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"github.com/image-server/image-server/info"
 	"github.com/image-server/image-server/logger"
 	adapter "github.com/image-server/image-server/processor/cli"
+	"github.com/image-server/image-server/processor/native"
 )
 
 type ProcessorResult struct {
@@ -32,6 +34,13 @@ type Processor struct {
 	ImageConfiguration *core.ImageConfiguration
 	ImageDetails       *info.ImageProperties
 	Channels           *ProcessorChannels
+	// Backend is the processor backend, core.ProcessorGo falls back to ImageMagick for unsupported outputs
+	Backend string
+	// Priority of the output when it waits for a worker
+	Priority Priority
+	// Context of the request waiting for the output. The processing is stopped once the contexts of every
+	// request waiting for it are done, nil is never done
+	Context context.Context
 }
 
 type ProcessorChannels struct {
@@ -53,27 +62,36 @@ func (p *Processor) CreateImage() error {
 
 func (p *Processor) uniqueCreateImage(c chan ProcessorResult) {
 	key := p.Destination
-	_, present := ImageProcessings[key]
 
 	processingMutex.Lock()
+	_, present := ImageProcessings[key]
 
 	if present {
 		ImageProcessings[key] = append(ImageProcessings[key], c)
+		processingWork[key].join(p.Context)
 		processingMutex.Unlock()
 		p.notifySkipped()
 	} else {
 		ImageProcessings[key] = []chan ProcessorResult{c}
+		w := newWork()
+		w.join(p.Context)
+		processingWork[key] = w
 		processingMutex.Unlock()
 
-		processed, err := p.createIfNotAvailable()
+		processed, err := p.createIfNotAvailable(w.ctx)
 
-		for _, cc := range ImageProcessings[key] {
-			cc <- ProcessorResult{p.Destination, err}
-			close(cc)
-		}
+		// requests joining once the channels are taken process the existing output again
 		processingMutex.Lock()
+		channels := ImageProcessings[key]
 		delete(ImageProcessings, key)
+		delete(processingWork, key)
 		processingMutex.Unlock()
+		w.cancel()
+
+		for _, cc := range channels {
+			cc <- ProcessorResult{p.Destination, err}
+			close(cc)
+		}
 
 		if processed {
 			p.notifyProcessed()
@@ -83,37 +101,88 @@ func (p *Processor) uniqueCreateImage(c chan ProcessorResult) {
 	}
 }
 
-func (p *Processor) createIfNotAvailable() (bool, error) {
+func (p *Processor) createIfNotAvailable(ctx context.Context) (bool, error) {
 	if _, err := os.Stat(p.Destination); os.IsNotExist(err) {
 		start := time.Now()
 
 		dir := filepath.Dir(p.Destination)
 		os.MkdirAll(dir, 0700)
 
-		processor := &adapter.Processor{
-			Source:             p.Source,
-			Destination:        p.Destination,
-			ImageConfiguration: p.ImageConfiguration,
-			ImageDetails:       p.ImageDetails,
+		release, err := scheduler.Acquire(ctx, p.Priority)
+		if err != nil {
+			return false, err
 		}
-		err = processor.CreateImage()
+		err = p.backend(ctx).CreateImage()
+		release()
 
+		err = p.created(start, err)
 		if err != nil {
-			logger.ImageProcessedWithErrors(p.ImageConfiguration)
-			log.Println(err)
 			return false, err
 		}
-
-		elapsed := time.Since(start)
-		glog.Infof("Took %s to generate image: %s", elapsed, p.Destination)
 		return true, nil
 	} else {
 		return false, nil
 	}
 }
 
+// created logs the result of creating the output
+func (p *Processor) created(start time.Time, err error) error {
+	if err != nil {
+		logger.ImageProcessedWithErrors(p.ImageConfiguration)
+		log.Println(err)
+		return err
+	}
+
+	if ic := p.ImageConfiguration; ic.QualityMode() != core.QualityFixed {
+		logger.QualitySelected(ic)
+		// the quality is served with the output, by every server
+		if err := core.SaveQuality(p.Destination, ic.Quality); err != nil {
+			glog.Errorf("Unable to save the quality of %s: %s", p.Destination, err)
+		}
+	}
+
+	elapsed := time.Since(start)
+	glog.Infof("Took %s to generate image: %s", elapsed, p.Destination)
+	return nil
+}
+
+// backend returns the Go processor when it is selected and supports the output, and ImageMagick otherwise.
+// Conversions are killed once ctx is done
+func (p *Processor) backend(ctx context.Context) core.Processor {
+	if n := p.nativeProcessor(); n != nil {
+		return n
+	}
+	return p.adapterProcessor(ctx)
+}
+
+func (p *Processor) nativeProcessor() *native.Processor {
+	if p.Backend != core.ProcessorGo || !native.Supports(p.ImageConfiguration, p.ImageDetails) {
+		return nil
+	}
+
+	return &native.Processor{
+		Source:             p.Source,
+		Destination:        p.Destination,
+		ImageConfiguration: p.ImageConfiguration,
+		ImageDetails:       p.ImageDetails,
+	}
+}
+
+func (p *Processor) adapterProcessor(ctx context.Context) *adapter.Processor {
+	return &adapter.Processor{
+		Source:             p.Source,
+		Destination:        p.Destination,
+		ImageConfiguration: p.ImageConfiguration,
+		ImageDetails:       p.ImageDetails,
+		Context:            ctx,
+	}
+}
+
 func (p *Processor) notifyProcessed() {
 	logger.ImageProcessed(p.ImageConfiguration)
+	if p.Channels == nil {
+		return
+	}
 
 	p.Channels.ImageProcessed <- p.ImageConfiguration
 	close(p.Channels.ImageProcessed)
@@ -122,6 +191,9 @@ func (p *Processor) notifyProcessed() {
 
 func (p *Processor) notifySkipped() {
 	logger.ImageAlreadyProcessed(p.ImageConfiguration)
+	if p.Channels == nil {
+		return
+	}
 
 	p.Channels.Skipped <- p.Destination
 	close(p.Channels.ImageProcessed)
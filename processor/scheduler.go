@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/image-server/image-server/core"
+)
+
+// Priority of an output, outputs with a higher priority are processed first
+type Priority int
+
+const (
+	// PriorityBulk is the priority of the outputs of posted images and batches
+	PriorityBulk Priority = iota
+	// PriorityOnDemand is the priority of the outputs requested by the resize handler, a client is waiting for them
+	PriorityOnDemand
+)
+
+const priorities = 2
+
+// Scheduler bounds the number of images processed at the same time. Outputs wait for a worker in a bounded queue,
+// higher priorities first, and are shed with an overloaded core.Error when the queue is full or when they wait
+// longer than the timeout
+type Scheduler struct {
+	workers int
+	queue   int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	running int
+	waiting [priorities][]chan error
+	// average processing time, used to estimate when the queue is drained
+	average time.Duration
+}
+
+// NewScheduler returns a scheduler with a pool of workers. A queue of 0 doesn't bound the number of waiting
+// outputs, a timeout of 0 waits until a worker is free, and 0 workers removes every limit
+func NewScheduler(workers uint, queue uint, timeout time.Duration) *Scheduler {
+	return &Scheduler{workers: int(workers), queue: int(queue), timeout: timeout}
+}
+
+// scheduler is used by every request, it doesn't limit processing until SetScheduler is called
+var scheduler = NewScheduler(0, 0, 0)
+
+// SetScheduler replaces the scheduler used by every request
+func SetScheduler(s *Scheduler) {
+	scheduler = s
+}
+
+// Acquire waits for a worker, the returned function releases it once the output is processed.
+// The output leaves the queue with the error of ctx once it is done
+func (s *Scheduler) Acquire(ctx context.Context, priority Priority) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if s.workers == 0 {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	if s.running < s.workers && s.queued() == 0 {
+		s.running++
+		s.mu.Unlock()
+		return s.releaser(time.Now()), nil
+	}
+
+	// a full queue makes room for higher priorities by shedding the last output of a lower priority
+	if s.queue > 0 && s.queued() >= s.queue && !s.shedLower(priority) {
+		err := s.overloaded()
+		s.mu.Unlock()
+		glog.Infof("Shedding an output, the processing queue is full: %s", err)
+		return nil, err
+	}
+
+	admitted := make(chan error, 1)
+	s.waiting[priority] = append(s.waiting[priority], admitted)
+	s.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if s.timeout > 0 {
+		timer := time.NewTimer(s.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-admitted:
+		return s.started(err)
+	case <-timeout:
+		return s.leave(priority, admitted, nil)
+	case <-ctx.Done():
+		return s.leave(priority, admitted, ctx.Err())
+	}
+}
+
+// leave removes an output from the queue once it times out, or once its context is done with err.
+// Outputs admitted in the meantime keep their worker when they time out, and release it otherwise
+func (s *Scheduler) leave(priority Priority, admitted chan error, err error) (func(), error) {
+	s.mu.Lock()
+	if s.remove(priority, admitted) {
+		if err == nil {
+			err = s.overloaded()
+			glog.Infof("Shedding an output, it waited %s for a worker: %s", s.timeout, err)
+		}
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	release, shed := s.started(<-admitted)
+	if shed != nil || err == nil {
+		return release, shed
+	}
+	release()
+	return nil, err
+}
+
+func (s *Scheduler) started(err error) (func(), error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.releaser(time.Now()), nil
+}
+
+// releaser returns the function releasing the worker, and admitting the next output
+func (s *Scheduler) releaser(start time.Time) func() {
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		elapsed := time.Since(start)
+		if s.average == 0 {
+			s.average = elapsed
+		} else {
+			s.average = (4*s.average + elapsed) / 5
+		}
+
+		s.running--
+		for p := priorities - 1; p >= 0; p-- {
+			if len(s.waiting[p]) > 0 {
+				admitted := s.waiting[p][0]
+				s.waiting[p] = s.waiting[p][1:]
+				s.running++
+				admitted <- nil
+				return
+			}
+		}
+	}
+}
+
+func (s *Scheduler) queued() int {
+	n := 0
+	for _, waiting := range s.waiting {
+		n += len(waiting)
+	}
+	return n
+}
+
+// shedLower sheds the last output waiting with the lowest priority below priority, false when there is none
+func (s *Scheduler) shedLower(priority Priority) bool {
+	for p := 0; p < int(priority); p++ {
+		if n := len(s.waiting[p]); n > 0 {
+			admitted := s.waiting[p][n-1]
+			s.waiting[p] = s.waiting[p][:n-1]
+			admitted <- s.overloaded()
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) remove(priority Priority, admitted chan error) bool {
+	for i, c := range s.waiting[priority] {
+		if c == admitted {
+			s.waiting[priority] = append(s.waiting[priority][:i], s.waiting[priority][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// overloaded returns an error with the time the workers need to process the queue, at least a second
+func (s *Scheduler) overloaded() error {
+	drained := s.average * time.Duration(s.queued()+1) / time.Duration(s.workers)
+	seconds := (drained + time.Second - 1) / time.Second
+	if seconds < 1 {
+		seconds = 1
+	}
+	return core.NewOverloadedError(seconds * time.Second)
+}
@@ -0,0 +1,102 @@
+package processor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/processor"
+	. "github.com/image-server/image-server/test"
+)
+
+// waitFor acquires a worker in the background, the channel returns the error of Acquire once it returns
+func waitFor(s *processor.Scheduler, priority processor.Priority, released chan bool) chan error {
+	c := make(chan error, 1)
+	go func() {
+		release, err := s.Acquire(context.Background(), priority)
+		c <- err
+		if err == nil {
+			<-released
+			release()
+		}
+	}()
+	// the outputs are queued in order
+	time.Sleep(10 * time.Millisecond)
+	return c
+}
+
+func TestSchedulerProcessesOnDemandOutputsFirst(t *testing.T) {
+	s := processor.NewScheduler(1, 0, 0)
+	release, err := s.Acquire(context.Background(), processor.PriorityBulk)
+	Ok(t, err)
+
+	released := make(chan bool)
+	defer close(released)
+	bulk := waitFor(s, processor.PriorityBulk, released)
+	onDemand := waitFor(s, processor.PriorityOnDemand, released)
+
+	release()
+	Ok(t, <-onDemand)
+	select {
+	case <-bulk:
+		t.Fatal("expected the bulk output to wait for the on demand output")
+	default:
+	}
+
+	released <- true
+	Ok(t, <-bulk)
+}
+
+func TestSchedulerShedsWhenTheQueueIsFull(t *testing.T) {
+	s := processor.NewScheduler(1, 1, 0)
+	release, err := s.Acquire(context.Background(), processor.PriorityOnDemand)
+	Ok(t, err)
+
+	released := make(chan bool)
+	defer close(released)
+	bulk := waitFor(s, processor.PriorityBulk, released)
+
+	// an on demand output takes the place of the bulk output
+	onDemand := waitFor(s, processor.PriorityOnDemand, released)
+	err = <-bulk
+	Assert(t, core.ErrorCode(err) == core.ErrorOverloaded, "expected the bulk output to be shed, got %v", err)
+
+	_, err = s.Acquire(context.Background(), processor.PriorityOnDemand)
+	Assert(t, core.ErrorCode(err) == core.ErrorOverloaded, "expected an on demand output to be shed, got %v", err)
+	Equals(t, time.Second, err.(*core.Error).RetryAfter)
+
+	release()
+	Ok(t, <-onDemand)
+}
+
+func TestSchedulerShedsAfterTheQueueTimeout(t *testing.T) {
+	s := processor.NewScheduler(1, 0, 20*time.Millisecond)
+	release, err := s.Acquire(context.Background(), processor.PriorityBulk)
+	Ok(t, err)
+
+	_, err = s.Acquire(context.Background(), processor.PriorityOnDemand)
+	Assert(t, core.ErrorCode(err) == core.ErrorOverloaded, "expected the output to be shed, got %v", err)
+
+	release()
+	release, err = s.Acquire(context.Background(), processor.PriorityOnDemand)
+	Ok(t, err)
+	release()
+}
+
+func TestSchedulerRemovesOutputsOfCanceledRequests(t *testing.T) {
+	s := processor.NewScheduler(1, 0, 0)
+	release, err := s.Acquire(context.Background(), processor.PriorityBulk)
+	Ok(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err = s.Acquire(ctx, processor.PriorityOnDemand)
+	Equals(t, context.Canceled, err)
+
+	// the canceled output doesn't take the worker
+	release()
+	release, err = s.Acquire(context.Background(), processor.PriorityBulk)
+	Ok(t, err)
+	release()
+}
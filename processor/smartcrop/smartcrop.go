@@ -0,0 +1,205 @@
+// Package smartcrop finds the most interesting area of an image to crop.
+// The analysis is done in pure Go, measuring the edge density and the entropy
+// of the luminance of every possible crop window.
+package smartcrop
+
+import (
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+
+	"github.com/image-server/image-server/info"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// analysisSize is the maximum width or height used to analyse the image
+const analysisSize = 256
+
+// histogramBins is the number of luminance buckets used to calculate the entropy
+const histogramBins = 32
+
+// FocalPoint returns the center of the crop window that keeps the most detail when the image on path,
+// displayed with its EXIF orientation, is cropped to fill width x height
+func FocalPoint(path string, orientation int, width int, height int) (*info.FocalPoint, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// the image is decoded as stored, the window is transposed and the point oriented instead of the pixels
+	if orientation >= 5 && orientation <= 8 {
+		width, height = height, width
+	}
+	return orient(Analyze(img, width, height), orientation), nil
+}
+
+// orient returns the point of the stored image displayed with an EXIF orientation
+func orient(fp *info.FocalPoint, orientation int) *info.FocalPoint {
+	x, y := fp.X, fp.Y
+	switch orientation {
+	case 2:
+		x = 1 - x
+	case 3:
+		x, y = 1-x, 1-y
+	case 4:
+		y = 1 - y
+	case 5:
+		x, y = y, x
+	case 6:
+		x, y = 1-y, x
+	case 7:
+		x, y = 1-y, 1-x
+	case 8:
+		x, y = y, 1-x
+	}
+	return &info.FocalPoint{X: x, Y: y}
+}
+
+// Analyze returns the center of the crop window with the highest score
+func Analyze(img image.Image, width int, height int) *info.FocalPoint {
+	center := &info.FocalPoint{X: 0.5, Y: 0.5}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 || width <= 0 || height <= 0 {
+		return center
+	}
+
+	g := newGrid(img)
+
+	// The crop window covers the image on one axis, and slides on the other
+	scale := math.Max(float64(width)/float64(bounds.Dx()), float64(height)/float64(bounds.Dy()))
+	windowCols := int(math.Floor(float64(width)/scale/float64(bounds.Dx())*float64(g.cols) + 0.5))
+	windowRows := int(math.Floor(float64(height)/scale/float64(bounds.Dy())*float64(g.rows) + 0.5))
+
+	if windowCols < g.cols {
+		x := g.bestWindow(windowCols, g.cols, func(offset int) image.Rectangle {
+			return image.Rect(offset, 0, offset+windowCols, g.rows)
+		})
+		center.X = (float64(x) + float64(windowCols)/2) / float64(g.cols)
+	} else if windowRows < g.rows {
+		y := g.bestWindow(windowRows, g.rows, func(offset int) image.Rectangle {
+			return image.Rect(0, offset, g.cols, offset+windowRows)
+		})
+		center.Y = (float64(y) + float64(windowRows)/2) / float64(g.rows)
+	}
+
+	return center
+}
+
+// grid is a downsampled luminance map of the image, with the edge magnitude of every cell
+type grid struct {
+	cols, rows int
+	luminance  []uint8
+	edges      []float64
+}
+
+func newGrid(img image.Image) *grid {
+	bounds := img.Bounds()
+	ratio := math.Max(float64(bounds.Dx()), float64(bounds.Dy())) / analysisSize
+	if ratio < 1 {
+		ratio = 1
+	}
+
+	g := &grid{
+		cols: int(math.Max(1, math.Floor(float64(bounds.Dx())/ratio))),
+		rows: int(math.Max(1, math.Floor(float64(bounds.Dy())/ratio))),
+	}
+	g.luminance = make([]uint8, g.cols*g.rows)
+	g.edges = make([]float64, g.cols*g.rows)
+
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			px := bounds.Min.X + int((float64(x)+0.5)*ratio)
+			py := bounds.Min.Y + int((float64(y)+0.5)*ratio)
+			r, gr, b, _ := img.At(px, py).RGBA()
+			// Rec. 601 luma, RGBA returns 16 bit channels
+			l := (299*r + 587*gr + 114*b) / 1000
+			g.luminance[y*g.cols+x] = uint8(l >> 8)
+		}
+	}
+
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			dx := float64(g.at(x+1, y)) - float64(g.at(x-1, y))
+			dy := float64(g.at(x, y+1)) - float64(g.at(x, y-1))
+			g.edges[y*g.cols+x] = math.Abs(dx) + math.Abs(dy)
+		}
+	}
+
+	return g
+}
+
+// at returns the luminance of a cell, clamping coordinates to the grid
+func (g *grid) at(x int, y int) uint8 {
+	if x < 0 {
+		x = 0
+	} else if x >= g.cols {
+		x = g.cols - 1
+	}
+	if y < 0 {
+		y = 0
+	} else if y >= g.rows {
+		y = g.rows - 1
+	}
+	return g.luminance[y*g.cols+x]
+}
+
+// bestWindow returns the offset of the window with the highest score.
+// Ties are resolved in favor of the window closest to the center
+func (g *grid) bestWindow(size int, length int, window func(int) image.Rectangle) int {
+	if size < 1 {
+		size = 1
+	}
+	best := (length - size) / 2
+	bestScore := -1.0
+	middle := float64(length-size) / 2
+
+	for offset := 0; offset <= length-size; offset++ {
+		score := g.score(window(offset))
+		if score > bestScore || (score == bestScore && math.Abs(float64(offset)-middle) < math.Abs(float64(best)-middle)) {
+			best = offset
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// score is the edge density of the window weighted by the entropy of its luminance
+func (g *grid) score(r image.Rectangle) float64 {
+	var histogram [histogramBins]int
+	edges := 0.0
+	cells := 0
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := y*g.cols + x
+			edges += g.edges[i]
+			histogram[int(g.luminance[i])*histogramBins/256]++
+			cells++
+		}
+	}
+
+	if cells == 0 {
+		return 0
+	}
+
+	entropy := 0.0
+	for _, count := range histogram {
+		if count > 0 {
+			p := float64(count) / float64(cells)
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return edges / float64(cells) * (1 + entropy)
+}
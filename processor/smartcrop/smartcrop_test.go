@@ -0,0 +1,78 @@
+package smartcrop_test
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/image-server/image-server/processor/smartcrop"
+	. "github.com/image-server/image-server/test"
+)
+
+// detailedImage returns a flat image with a checkerboard on the given area
+func detailedImage(width int, height int, detail image.Rectangle) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{200, 200, 200, 255}
+			if (image.Point{x, y}).In(detail) && (x/4+y/4)%2 == 0 {
+				c = color.RGBA{20, 20, 20, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestAnalyzeHorizontalCrop(t *testing.T) {
+	img := detailedImage(400, 200, image.Rect(300, 50, 380, 150))
+
+	fp := smartcrop.Analyze(img, 100, 100)
+	Assert(t, fp.X >= 0.7, "expected focal point to be on the right, got %v", fp.X)
+	Equals(t, 0.5, fp.Y)
+}
+
+func TestAnalyzeVerticalCrop(t *testing.T) {
+	img := detailedImage(200, 400, image.Rect(50, 10, 150, 90))
+
+	fp := smartcrop.Analyze(img, 200, 100)
+	Assert(t, fp.Y < 0.25, "expected focal point to be on the top, got %v", fp.Y)
+	Equals(t, 0.5, fp.X)
+}
+
+func TestAnalyzeFlatImage(t *testing.T) {
+	img := detailedImage(400, 200, image.Rectangle{})
+
+	fp := smartcrop.Analyze(img, 100, 100)
+	Equals(t, 0.5, fp.X)
+	Equals(t, 0.5, fp.Y)
+}
+
+func TestFocalPointFromFile(t *testing.T) {
+	fp, err := smartcrop.FocalPoint("../../test/images/a.jpg", 1, 100, 300)
+	Ok(t, err)
+	Assert(t, fp.X >= 0 && fp.X <= 1, "expected focal point within the image, got %v", fp.X)
+	Equals(t, 0.5, fp.Y)
+}
+
+func TestFocalPointOfOrientedImage(t *testing.T) {
+	// stored as a landscape with the detail on the left, displayed as a portrait with the detail on the top
+	file, err := ioutil.TempFile("", "orientation_6")
+	Ok(t, err)
+	defer os.Remove(file.Name())
+	Ok(t, png.Encode(file, detailedImage(400, 200, image.Rect(10, 0, 80, 200))))
+	Ok(t, file.Close())
+
+	fp, err := smartcrop.FocalPoint(file.Name(), 6, 200, 100)
+	Ok(t, err)
+	Equals(t, 0.5, fp.X)
+	Assert(t, fp.Y < 0.25, "expected focal point to be on the top, got %v", fp.Y)
+
+	// the stored image displayed as is keeps the detail on the left
+	fp, err = smartcrop.FocalPoint(file.Name(), 1, 100, 200)
+	Ok(t, err)
+	Assert(t, fp.X < 0.25, "expected focal point to be on the left, got %v", fp.X)
+}
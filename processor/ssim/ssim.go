@@ -0,0 +1,132 @@
+// Package ssim measures the structural similarity (SSIM) of two renditions of an image.
+// The index is the mean SSIM of the luminance, computed on overlapping 8x8 windows.
+package ssim
+
+import (
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	_ "golang.org/x/image/webp"
+)
+
+// window is the width and height of the compared windows, windows overlap by half their size
+const window = 8
+
+// stabilizing constants for 8 bit luminance: (0.01 * 255)^2 and (0.03 * 255)^2
+const (
+	c1 = 6.5025
+	c2 = 58.5225
+)
+
+// CompareFiles returns the SSIM index of two image files
+func CompareFiles(reference string, path string) (float64, error) {
+	a, err := decode(reference)
+	if err != nil {
+		return 0, err
+	}
+
+	b, err := decode(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return Index(a, b), nil
+}
+
+func decode(path string) (image.Image, error) {
+	reader, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	img, _, err := image.Decode(reader)
+	return img, err
+}
+
+// Index returns the SSIM index of two images, from 0 to 1 for identical images.
+// Images of different dimensions have an index of 0
+func Index(a image.Image, b image.Image) float64 {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return 0
+	}
+
+	la, lb := luminance(a), luminance(b)
+	width, height := a.Bounds().Dx(), a.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return 0
+	}
+
+	w, h := window, window
+	if width < w {
+		w = width
+	}
+	if height < h {
+		h = height
+	}
+
+	total := 0.0
+	count := 0
+	for y := 0; y+h <= height; y += (h + 1) / 2 {
+		for x := 0; x+w <= width; x += (w + 1) / 2 {
+			total += windowIndex(la, lb, width, image.Rect(x, y, x+w, y+h))
+			count++
+		}
+	}
+	return total / float64(count)
+}
+
+// windowIndex returns the SSIM of a window of two luminance maps
+func windowIndex(a []float64, b []float64, stride int, r image.Rectangle) float64 {
+	n := float64(r.Dx() * r.Dy())
+
+	var sumA, sumB float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sumA += a[y*stride+x]
+			sumB += b[y*stride+x]
+		}
+	}
+	meanA, meanB := sumA/n, sumB/n
+
+	var varA, varB, covariance float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			da := a[y*stride+x] - meanA
+			db := b[y*stride+x] - meanB
+			varA += da * da
+			varB += db * db
+			covariance += da * db
+		}
+	}
+	varA, varB, covariance = varA/n, varB/n, covariance/n
+
+	return ((2*meanA*meanB + c1) * (2*covariance + c2)) /
+		((meanA*meanA + meanB*meanB + c1) * (varA + varB + c2))
+}
+
+// luminance returns the 8 bit luma of every pixel, the Y plane is used for YCbCr images
+func luminance(img image.Image) []float64 {
+	bounds := img.Bounds()
+	l := make([]float64, 0, bounds.Dx()*bounds.Dy())
+
+	if ycbcr, ok := img.(*image.YCbCr); ok {
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				l = append(l, float64(ycbcr.Y[ycbcr.YOffset(x, y)]))
+			}
+		}
+		return l
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			// Rec. 601 luma, RGBA returns 16 bit channels
+			l = append(l, float64(299*r+587*g+114*b)/1000/257)
+		}
+	}
+	return l
+}
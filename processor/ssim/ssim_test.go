@@ -0,0 +1,64 @@
+package ssim_test
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/image-server/image-server/processor/ssim"
+	. "github.com/image-server/image-server/test"
+)
+
+// gradient returns an image with a horizontal gradient and a checkerboard of the given contrast
+func gradient(contrast uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(x * 3)
+			if (x/4+y/4)%2 == 0 {
+				v += contrast
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestIndexOfIdenticalImages(t *testing.T) {
+	Equals(t, 1.0, ssim.Index(gradient(40), gradient(40)))
+}
+
+func TestIndexOfDifferentImages(t *testing.T) {
+	similar := ssim.Index(gradient(40), gradient(36))
+	different := ssim.Index(gradient(40), gradient(0))
+
+	Assert(t, similar > 0.9 && similar < 1, "expected a similar image, got %f", similar)
+	Assert(t, different < similar, "expected %f to be lower than %f", different, similar)
+}
+
+func TestIndexOfDifferentDimensions(t *testing.T) {
+	Equals(t, 0.0, ssim.Index(gradient(40), image.NewRGBA(image.Rect(0, 0, 10, 10))))
+}
+
+func TestCompareFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssim")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	reference := filepath.Join(dir, "q95.jpg")
+	lossy := filepath.Join(dir, "q20.jpg")
+	for path, quality := range map[string]int{reference: 95, lossy: 20} {
+		f, err := os.Create(path)
+		Ok(t, err)
+		Ok(t, jpeg.Encode(f, gradient(40), &jpeg.Options{Quality: quality}))
+		f.Close()
+	}
+
+	index, err := ssim.CompareFiles(reference, lossy)
+	Ok(t, err)
+	Assert(t, index > 0.5 && index < 1, "expected a lossy rendition, got %f", index)
+}
@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"context"
+	"sync"
+)
+
+// work is the processing of outputs shared by every request waiting for them, it is canceled once every request is gone
+type work struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	waiters int
+}
+
+// processingWork maps the destination of the outputs being processed to their work, it is protected by processingMutex
+var processingWork = map[string]*work{}
+
+func newWork() *work {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &work{ctx: ctx, cancel: cancel}
+}
+
+// join adds a request waiting for the work. Requests with a context that is never done keep the work running
+func (w *work) join(ctx context.Context) {
+	w.mu.Lock()
+	w.waiters++
+	w.mu.Unlock()
+
+	if ctx == nil || ctx.Done() == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.mu.Lock()
+			w.waiters--
+			gone := w.waiters == 0
+			w.mu.Unlock()
+			if gone {
+				w.cancel()
+			}
+		case <-w.ctx.Done():
+		}
+	}()
+}
@@ -3,6 +3,7 @@ package request
 import (
 	"log"
 
+	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/fetcher"
 	"github.com/image-server/image-server/info"
 	"github.com/image-server/image-server/uploader"
@@ -10,6 +11,7 @@ import (
 
 func (r *Request) Create() (*info.ImageProperties, error) {
 	f := fetcher.NewSourceFetcher(r.Paths)
+	f.ServerConfiguration = r.ServerConfiguration
 	var imageDetails *info.ImageProperties
 	var downloaded bool
 	var err error
@@ -62,6 +64,10 @@ func (r *Request) UploadOriginal(imageDetails *info.ImageProperties) error {
 
 	destination := r.Paths.RemoteOriginalPath(r.Namespace, imageDetails.Hash)
 
+	// Keep the focal point when the original is uploaded again
+	if imageDetails.FocalPoint == nil {
+		imageDetails.FocalPoint = r.storedFocalPoint()
+	}
 	r.UploadImageDetails(imageDetails, uploader)
 
 	// upload original image
@@ -72,14 +78,14 @@ func (r *Request) UploadOriginal(imageDetails *info.ImageProperties) error {
 	return nil
 }
 
-func (r *Request) UploadImageDetails(imageDetails *info.ImageProperties, uploader *uploader.Uploader) {
+func (r *Request) UploadImageDetails(imageDetails *info.ImageProperties, uploader core.Uploader) error {
 	localInfoPath := r.Paths.LocalInfoPath(r.Namespace, imageDetails.Hash)
 	remoteInfoPath := r.Paths.RemoteInfoPath(r.Namespace, imageDetails.Hash)
 
 	err := info.SaveImageDetail(imageDetails, localInfoPath)
 	if err != nil {
 		log.Println(err)
-		return
+		return err
 	}
 
 	// upload info
@@ -87,4 +93,5 @@ func (r *Request) UploadImageDetails(imageDetails *info.ImageProperties, uploade
 	if err != nil {
 		log.Println(err)
 	}
+	return err
 }
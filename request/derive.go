@@ -0,0 +1,147 @@
+package request
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/glog"
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/fetcher"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/parser"
+)
+
+// source returns the image an output is resized from: an existing larger output when the namespace
+// derives outputs, or the original
+func (r *Request) source(ic *core.ImageConfiguration) (string, *info.ImageProperties, error) {
+	path, id, ok := r.derivative(ic)
+	if ok {
+		return path, id, nil
+	}
+	return r.original()
+}
+
+// derivative returns the local path and the image details of the smallest existing output the output can be
+// resized from with a bounded loss of quality, and false when there is none. Only the details of the original
+// (info.json) are fetched, the original is not downloaded
+func (r *Request) derivative(ic *core.ImageConfiguration) (string, *info.ImageProperties, bool) {
+	dc := r.ServerConfiguration.DeriveFor(r.Namespace)
+	if dc == nil || !derivable(ic) {
+		return "", nil, false
+	}
+
+	original, err := r.storedImageDetails()
+	if err != nil || original.Animated() || original.Width == 0 || original.Height == 0 {
+		return "", nil, false
+	}
+
+	// the existing output must be larger than the output by at least the minimum scale
+	minimum := dc.MinimumScale() * ic.Scale(original.Width, original.Height)
+	var best *core.ImageConfiguration
+	var bestScale float64
+	for _, filename := range r.existingOutputs() {
+		if filename == ic.Filename {
+			continue
+		}
+		d, err := parser.NameToConfiguration(r.ServerConfiguration, r.Namespace, filename)
+		if err != nil || !derivesFrom(ic, d, original, dc) {
+			continue
+		}
+
+		scale := d.Scale(original.Width, original.Height)
+		if scale < minimum || best != nil && scale >= bestScale {
+			continue
+		}
+		best, bestScale = d, scale
+	}
+	if best == nil {
+		return "", nil, false
+	}
+
+	best.ID = r.Hash
+	path := r.Paths.LocalImagePath(r.Namespace, r.Hash, best.Filename)
+	if _, err := os.Stat(path); err != nil {
+		if err := fetcher.NewProcessedFetcher(r.Paths).Fetch(best); err != nil {
+			glog.Infof("Unable to fetch %s to derive %s: %s", best.Filename, ic.Filename, err)
+			return "", nil, false
+		}
+	}
+
+	i := &info.Info{Path: path}
+	id, err := i.ImageDetails()
+	if err != nil {
+		return "", nil, false
+	}
+	id.FocalPoint = original.FocalPoint
+
+	glog.Infof("Deriving %s from %s", ic.Filename, best.Filename)
+	return path, id, true
+}
+
+// existingOutputs returns the filenames of the outputs stored locally or remotely
+func (r *Request) existingOutputs() []string {
+	var filenames []string
+	entries, _ := ioutil.ReadDir(r.Paths.LocalImageDirectory(r.Namespace, r.Hash))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			filenames = append(filenames, entry.Name())
+		}
+	}
+
+	err := r.FetchRemoteFileListing()
+	if err != nil {
+		glog.Infof("Unable to list remote files for %s/%s: %s", r.Namespace, r.Hash, err)
+	}
+	for filename := range r.directoryListing {
+		filenames = append(filenames, filename)
+	}
+	return filenames
+}
+
+// derivable returns true when an output only needs the resized original, without its metadata
+func derivable(ic *core.ImageConfiguration) bool {
+	return ic.Width > 0 && ic.Region == nil && !ic.Trim && ic.MetadataPolicy() == core.MetadataStrip &&
+		ic.QualityMode() == core.QualityFixed
+}
+
+// derivesFrom returns true when the existing output d shows the part of the original shown by ic,
+// without filters, and was encoded with a bounded loss of quality
+func derivesFrom(ic *core.ImageConfiguration, d *core.ImageConfiguration, original *info.ImageProperties, dc *core.DeriveConfiguration) bool {
+	if d.Region != nil || d.Trim || d.Rotate != 0 || d.Flip || d.Flop || d.Gray || d.Brightness != 0 ||
+		d.Contrast != 0 || d.Blur != 0 || d.Sharpen != 0 || d.Circle || d.Colors != 0 ||
+		d.QualityMode() != core.QualityFixed {
+		return false
+	}
+
+	// upscaled outputs are blurry
+	if d.Scale(original.Width, original.Height) > 1 {
+		return false
+	}
+
+	switch d.Format {
+	case "jpg", "jpeg":
+		if d.Quality < dc.MinimumQuality() {
+			return false
+		}
+	case "webp":
+		if !d.Lossless && d.Quality < dc.MinimumQuality() {
+			return false
+		}
+	case "png":
+	default:
+		return false
+	}
+
+	if original.Transparent() && !d.TransparentBackground() {
+		return false
+	}
+
+	// outputs by width, full size and fit outputs show the whole original
+	if d.Height == 0 || d.ResizeMode() == core.ResizeModeFit {
+		return true
+	}
+
+	// crops with the aspect ratio and the gravity of the output show the same part of the original
+	return d.ResizeMode() == core.ResizeModeCrop && ic.ResizeMode() == core.ResizeModeCrop && ic.Height > 0 &&
+		ic.Rotate == 0 && !ic.Flip && !ic.Flop && d.Gravity == ic.Gravity && d.Width*ic.Height == d.Height*ic.Width
+}
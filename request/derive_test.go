@@ -0,0 +1,93 @@
+package request_test
+
+import (
+	"encoding/json"
+	"image"
+	_ "image/jpeg"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/paths"
+	"github.com/image-server/image-server/request"
+	. "github.com/image-server/image-server/test"
+)
+
+// derivingRequest returns a request for an original that is not available, only its
+// image details (info.json) and a 574px wide output are stored locally
+func derivingRequest(t *testing.T, dir string, derive *core.DeriveConfiguration) *request.Request {
+	sc := &core.ServerConfiguration{
+		DefaultQuality: 90,
+		Processor:      core.ProcessorGo,
+		Namespaces:     map[string]*core.NamespaceConfiguration{"p": {Derive: derive}},
+	}
+	r := &request.Request{
+		ServerConfiguration: sc,
+		Uploader:            &FakeUploader{},
+		Paths:               &paths.Paths{LocalBasePath: dir},
+		Namespace:           "p",
+		Hash:                "f944de07734f1868a4355e1b86052704",
+	}
+
+	original := &info.ImageProperties{Width: 1148, Height: 992, ContentType: "image/jpeg"}
+	b, err := json.Marshal(original)
+	Ok(t, err)
+	Ok(t, os.MkdirAll(r.Paths.LocalImageDirectory(r.Namespace, r.Hash), 0700))
+	Ok(t, ioutil.WriteFile(r.Paths.LocalInfoPath(r.Namespace, r.Hash), b, 0600))
+
+	b, err = ioutil.ReadFile("../test/images/a.jpg")
+	Ok(t, err)
+	Ok(t, ioutil.WriteFile(r.Paths.LocalImagePath(r.Namespace, r.Hash, "w574.jpg"), b, 0600))
+	return r
+}
+
+func TestProcessDerivesFromLargerOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "derive")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	r := derivingRequest(t, dir, &core.DeriveConfiguration{})
+	ic := &core.ImageConfiguration{ID: r.Hash, Namespace: "p", Filename: "x100.jpg", Width: 100, Height: 100, Format: "jpg", Quality: 90}
+	Ok(t, r.Process(ic))
+
+	reader, err := os.Open(filepath.Join(r.Paths.LocalImageDirectory(r.Namespace, r.Hash), "x100.jpg"))
+	Ok(t, err)
+	config, _, err := image.DecodeConfig(reader)
+	reader.Close()
+	Ok(t, err)
+	Equals(t, 100, config.Width)
+	Equals(t, 100, config.Height)
+}
+
+func TestProcessDoesNotDeriveWhenTheLossIsNotBounded(t *testing.T) {
+	dir, err := ioutil.TempDir("", "derive")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		derive *core.DeriveConfiguration
+		ic     *core.ImageConfiguration
+	}{
+		// the namespace doesn't derive outputs
+		{nil, &core.ImageConfiguration{Filename: "x100.jpg", Width: 100, Height: 100, Format: "jpg"}},
+		// the existing output is less than 3 times larger
+		{&core.DeriveConfiguration{Scale: 3}, &core.ImageConfiguration{Filename: "x200.jpg", Width: 200, Height: 200, Format: "jpg"}},
+		// the existing output has a lower quality
+		{&core.DeriveConfiguration{Quality: 95}, &core.ImageConfiguration{Filename: "x100.jpg", Width: 100, Height: 100, Format: "jpg"}},
+		// regions need the original
+		{&core.DeriveConfiguration{}, &core.ImageConfiguration{Filename: "c0,0,10,10-x10.jpg", Width: 10, Height: 10, Format: "jpg", Region: &core.Region{Width: 10, Height: 10}}},
+	}
+
+	for _, test := range tests {
+		r := derivingRequest(t, dir, test.derive)
+		test.ic.ID = r.Hash
+		test.ic.Namespace = r.Namespace
+
+		// the original is not available
+		err := r.Process(test.ic)
+		Assert(t, err != nil, "expected %s to be resized from the original", test.ic.Filename)
+	}
+}
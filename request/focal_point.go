@@ -0,0 +1,184 @@
+package request
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/fetcher"
+	httpFetcher "github.com/image-server/image-server/fetcher/http"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/parser"
+)
+
+// focalPointRevalidation is the time the focal point of a local info.json is trusted. Older details are
+// compared with the stored ones, so crops of a focal point updated on another server are generated again
+const focalPointRevalidation = time.Minute
+
+// UpdateFocalPoint stores the focal point in the image details (info.json).
+// Every version cropped around the previous focal point is removed locally
+// and from the remote store, so it gets generated again on the next request
+func (r *Request) UpdateFocalPoint(fp *info.FocalPoint) (*info.ImageProperties, error) {
+	imageDetails, err := r.ImageDetails()
+	if err != nil {
+		return nil, err
+	}
+	imageDetails.FocalPoint = fp
+
+	err = r.UploadImageDetails(imageDetails, r.Uploader)
+	if err != nil {
+		return nil, err
+	}
+
+	r.removeFocalPointOutputs()
+	return imageDetails, nil
+}
+
+// ImageDetails returns the stored image details (info.json).
+// The details are calculated from the original image when they are not available
+func (r *Request) ImageDetails() (*info.ImageProperties, error) {
+	imageDetails, err := r.storedImageDetails()
+	if err == nil {
+		return imageDetails, nil
+	}
+
+	err = r.DownloadOriginal()
+	if err != nil {
+		return nil, err
+	}
+
+	i := &info.Info{Path: r.Paths.LocalOriginalPath(r.Namespace, r.Hash)}
+	return i.ImageDetails()
+}
+
+// storedFocalPoint returns the focal point saved in info.json, nil when there is none
+func (r *Request) storedFocalPoint() *info.FocalPoint {
+	imageDetails, err := r.storedImageDetails()
+	if err != nil {
+		return nil
+	}
+	return imageDetails.FocalPoint
+}
+
+func (r *Request) storedImageDetails() (*info.ImageProperties, error) {
+	localInfoPath := r.Paths.LocalInfoPath(r.Namespace, r.Hash)
+	remoteInfoURL := r.Paths.RemoteImageURL(r.Namespace, r.Hash, "info.json")
+
+	f := fetcher.NewUniqueFetcher(remoteInfoURL, localInfoPath)
+	_, err := f.Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	return info.LoadImageDetail(localInfoPath)
+}
+
+// revalidateFocalPoint compares a local info.json trusted longer than focalPointRevalidation with the stored one
+// in the background, the versions served meanwhile might be cropped around the previous focal point
+func (r *Request) revalidateFocalPoint() {
+	localInfoPath := r.Paths.LocalInfoPath(r.Namespace, r.Hash)
+	stat, err := os.Stat(localInfoPath)
+	if err != nil || time.Since(stat.ModTime()) < focalPointRevalidation {
+		return
+	}
+	// trusted again until the next revalidation, even when the stored details can't be downloaded
+	now := time.Now()
+	os.Chtimes(localInfoPath, now, now)
+
+	go r.compareFocalPoint()
+}
+
+// compareFocalPoint replaces the local info.json by the stored one, and removes the local versions cropped
+// around the focal point when it was updated on another server
+func (r *Request) compareFocalPoint() {
+	localInfoPath := r.Paths.LocalInfoPath(r.Namespace, r.Hash)
+	local, err := info.LoadImageDetail(localInfoPath)
+	if err != nil {
+		return
+	}
+
+	// downloaded next to the local info.json, which is replaced by renaming it
+	dir, err := ioutil.TempDir(filepath.Dir(localInfoPath), "revalidate")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	storedInfoPath := filepath.Join(dir, "info.json")
+	f := &httpFetcher.Fetcher{}
+	err = f.Fetch(r.Paths.RemoteImageURL(r.Namespace, r.Hash, "info.json"), storedInfoPath)
+	if err != nil {
+		glog.Infof("Unable to revalidate the focal point of %s/%s: %s", r.Namespace, r.Hash, err)
+		return
+	}
+	stored, err := info.LoadImageDetail(storedInfoPath)
+	if err != nil {
+		return
+	}
+
+	if sameFocalPoint(local.FocalPoint, stored.FocalPoint) {
+		return
+	}
+	glog.Infof("The focal point of %s/%s was updated, removing its local crops", r.Namespace, r.Hash)
+	os.Rename(storedInfoPath, localInfoPath)
+	r.removeLocalFocalPointOutputs()
+}
+
+func sameFocalPoint(a *info.FocalPoint, b *info.FocalPoint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// removeFocalPointOutputs deletes the versions that are cropped around the focal point
+func (r *Request) removeFocalPointOutputs() {
+	err := r.FetchRemoteFileListing()
+	if err != nil {
+		glog.Errorf("Unable to list remote files for %s/%s: %s", r.Namespace, r.Hash, err)
+	}
+
+	for filename := range r.directoryListing {
+		if r.usesFocalPoint(filename) {
+			r.Uploader.Delete(r.Paths.RemoteImagePath(r.Namespace, r.Hash, filename))
+		}
+	}
+
+	r.removeLocalFocalPointOutputs()
+}
+
+// removeLocalFocalPointOutputs deletes the local versions that are cropped around the focal point
+func (r *Request) removeLocalFocalPointOutputs() {
+	entries, err := ioutil.ReadDir(r.Paths.LocalImageDirectory(r.Namespace, r.Hash))
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && r.usesFocalPoint(entry.Name()) {
+			os.Remove(r.Paths.LocalImagePath(r.Namespace, r.Hash, entry.Name()))
+		}
+	}
+}
+
+// usesFocalPoint returns true when the output is cropped without an explicit gravity, and for the quality
+// files of these outputs
+func (r *Request) usesFocalPoint(filename string) bool {
+	filename = strings.TrimSuffix(filename, core.QualityExtension)
+	ic, err := parser.NameToConfiguration(r.ServerConfiguration, r.Namespace, filename)
+	if err != nil {
+		return false
+	}
+	return cropsAroundFocalPoint(ic)
+}
+
+// cropsAroundFocalPoint returns true for crops without an explicit gravity, and for smart crops which
+// use the stored focal point when the image can't be analyzed
+func cropsAroundFocalPoint(ic *core.ImageConfiguration) bool {
+	gravity := ic.Gravity == "" || ic.Gravity == core.GravitySmart
+	return ic.Width > 0 && ic.Height > 0 && ic.ResizeMode() == core.ResizeModeCrop && gravity
+}
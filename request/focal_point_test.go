@@ -0,0 +1,141 @@
+package request_test
+
+import (
+	"encoding/json"
+	"image"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/paths"
+	"github.com/image-server/image-server/request"
+	. "github.com/image-server/image-server/test"
+)
+
+// updatedFocalPointRequest returns a request for an image with a crop cached locally around the
+// center, the focal point stored remotely was moved by another server
+func updatedFocalPointRequest(t *testing.T, dir string, remote string) *request.Request {
+	r := &request.Request{
+		ServerConfiguration: &core.ServerConfiguration{DefaultQuality: 90, Processor: core.ProcessorGo},
+		Uploader:            &FakeUploader{},
+		Paths:               &paths.Paths{LocalBasePath: dir, RemoteBaseURL: remote},
+		Namespace:           "p",
+		Hash:                "f944de07734f1868a4355e1b86052704",
+	}
+
+	local := &info.ImageProperties{Hash: r.Hash, Width: 574, Height: 496, ContentType: "image/jpeg", FocalPoint: &info.FocalPoint{X: 0.5, Y: 0.5}}
+	b, err := json.Marshal(local)
+	Ok(t, err)
+	Ok(t, os.MkdirAll(r.Paths.LocalImageDirectory(r.Namespace, r.Hash), 0700))
+	Ok(t, ioutil.WriteFile(r.Paths.LocalInfoPath(r.Namespace, r.Hash), b, 0600))
+	Ok(t, ioutil.WriteFile(r.Paths.LocalImagePath(r.Namespace, r.Hash, "100x100.jpg"), []byte("centered crop"), 0600))
+	return r
+}
+
+func remoteImages(t *testing.T, focalPoint *info.FocalPoint) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/info.json"):
+			stored := &info.ImageProperties{Width: 574, Height: 496, ContentType: "image/jpeg", FocalPoint: focalPoint}
+			json.NewEncoder(w).Encode(stored)
+		case strings.HasSuffix(req.URL.Path, "/original"):
+			http.ServeFile(w, req, "../test/images/a.jpg")
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+}
+
+func TestProcessRemovesCropsOfAFocalPointUpdatedElsewhere(t *testing.T) {
+	dir, err := ioutil.TempDir("", "focal")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := remoteImages(t, &info.FocalPoint{X: 0.2, Y: 0.3})
+	defer ts.Close()
+
+	r := updatedFocalPointRequest(t, dir, ts.URL)
+	localInfoPath := r.Paths.LocalInfoPath(r.Namespace, r.Hash)
+	old := time.Now().Add(-2 * time.Minute)
+	Ok(t, os.Chtimes(localInfoPath, old, old))
+
+	ic := &core.ImageConfiguration{ID: r.Hash, Namespace: "p", Filename: "100x100.jpg", Width: 100, Height: 100, Format: "jpg", Quality: 90}
+	Ok(t, r.Process(ic))
+
+	// the focal point is revalidated in the background, the crop is removed once it is compared
+	cropPath := r.Paths.LocalImagePath(r.Namespace, r.Hash, "100x100.jpg")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(cropPath); os.IsNotExist(err) {
+			break
+		}
+		Assert(t, time.Now().Before(deadline), "expected the crop of the previous focal point to be removed")
+	}
+
+	details, err := info.LoadImageDetail(localInfoPath)
+	Ok(t, err)
+	Equals(t, &info.FocalPoint{X: 0.2, Y: 0.3}, details.FocalPoint)
+
+	// the crop is generated again around the updated focal point
+	Ok(t, r.Process(ic))
+	reader, err := os.Open(cropPath)
+	Ok(t, err)
+	defer reader.Close()
+	_, _, err = image.DecodeConfig(reader)
+	Ok(t, err)
+}
+
+func TestProcessTrustsRecentFocalPoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "focal")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := remoteImages(t, &info.FocalPoint{X: 0.2, Y: 0.3})
+	defer ts.Close()
+
+	r := updatedFocalPointRequest(t, dir, ts.URL)
+	ic := &core.ImageConfiguration{ID: r.Hash, Namespace: "p", Filename: "100x100.jpg", Width: 100, Height: 100, Format: "jpg", Quality: 90}
+	Ok(t, r.Process(ic))
+
+	b, err := ioutil.ReadFile(r.Paths.LocalImagePath(r.Namespace, r.Hash, "100x100.jpg"))
+	Ok(t, err)
+	Equals(t, "centered crop", string(b))
+}
+
+// recordingUploader records the destinations of the uploaded files
+type recordingUploader struct {
+	FakeUploader
+	uploaded []string
+}
+
+func (u *recordingUploader) Upload(source string, destination string, contentType string) error {
+	u.uploaded = append(u.uploaded, destination)
+	return nil
+}
+
+func TestUpdateFocalPointUploadsWithTheUploaderOfTheRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "focal")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := remoteImages(t, nil)
+	defer ts.Close()
+
+	r := updatedFocalPointRequest(t, dir, ts.URL)
+	u := &recordingUploader{}
+	r.Uploader = u
+
+	details, err := r.UpdateFocalPoint(&info.FocalPoint{X: 0.2, Y: 0.3})
+	Ok(t, err)
+	Equals(t, &info.FocalPoint{X: 0.2, Y: 0.3}, details.FocalPoint)
+	Equals(t, []string{r.Paths.RemoteInfoPath(r.Namespace, r.Hash)}, u.uploaded)
+
+	// the crop around the previous focal point is removed
+	_, err = os.Stat(r.Paths.LocalImagePath(r.Namespace, r.Hash, "100x100.jpg"))
+	Equals(t, true, os.IsNotExist(err))
+}
@@ -10,6 +10,10 @@ import (
 
 // Process downloads or processes an image version
 func (r *Request) Process(ic *core.ImageConfiguration) error {
+	if cropsAroundFocalPoint(ic) {
+		r.revalidateFocalPoint()
+	}
+
 	err := r.downloadProcessed(ic)
 	if err == nil {
 		return nil
@@ -22,15 +26,36 @@ func (r *Request) Process(ic *core.ImageConfiguration) error {
 // downloadProcessed download an existing image that has been already processed
 func (r *Request) downloadProcessed(ic *core.ImageConfiguration) error {
 	f := fetcher.NewProcessedFetcher(r.Paths)
-	return f.Fetch(ic)
+	err := f.Fetch(ic)
+	if err != nil || ic.QualityMode() == core.QualityFixed {
+		return err
+	}
+
+	// the quality is missing from outputs stored before it was saved
+	qualityFile := core.QualityFile(ic.Filename)
+	uf := fetcher.NewUniqueFetcher(r.Paths.RemoteImageURL(ic.Namespace, ic.ID, qualityFile), r.Paths.LocalImagePath(ic.Namespace, ic.ID, qualityFile))
+	if _, err := uf.Fetch(); err != nil {
+		glog.Infof("Unable to download the quality of %s: %s", ic.Filename, err)
+	}
+	return nil
+}
+
+// OutputQuality returns the quality selected for a processed output without a fixed quality
+func (r *Request) OutputQuality(ic *core.ImageConfiguration) (uint, bool) {
+	if ic.QualityMode() == core.QualityFixed {
+		return 0, false
+	}
+
+	quality, err := core.LoadQuality(r.Paths.LocalImagePath(ic.Namespace, ic.ID, ic.Filename))
+	if err != nil {
+		return 0, false
+	}
+	return quality, true
 }
 
 func (r *Request) processImage(ic *core.ImageConfiguration) error {
 	localResizedPath := r.Paths.LocalImagePath(r.Namespace, r.Hash, ic.Filename)
-	localOriginalPath := r.Paths.LocalOriginalPath(r.Namespace, r.Hash)
-
-	// The original file will be downloaded only once, even when every dimension requests it
-	err := r.DownloadOriginal()
+	localSourcePath, id, err := r.source(ic)
 	if err != nil {
 		return err
 	}
@@ -41,20 +66,20 @@ func (r *Request) processImage(ic *core.ImageConfiguration) error {
 		Skipped:        make(chan string),
 	}
 
-	info := &info.Info{
-		Path: localOriginalPath,
-	}
-	id, err := info.ImageDetails()
+	err = r.checkLimits(ic, id)
 	if err != nil {
 		return err
 	}
 
 	p := processor.Processor{
-		Source:             localOriginalPath,
+		Source:             localSourcePath,
 		Destination:        localResizedPath,
 		ImageConfiguration: ic,
 		ImageDetails:       id,
 		Channels:           pchan,
+		Backend:            r.ServerConfiguration.Processor,
+		Priority:           processor.PriorityOnDemand,
+		Context:            r.Context,
 	}
 
 	err = p.CreateImage()
@@ -74,6 +99,76 @@ func (r *Request) processImage(ic *core.ImageConfiguration) error {
 	return nil
 }
 
+// processOutputs creates every output from one download of the original, every output is checked
+// before processing starts. The outputs are not uploaded
+func (r *Request) processOutputs(configurations []*core.ImageConfiguration) error {
+	localOriginalPath, id, err := r.original()
+	if err != nil {
+		return err
+	}
+
+	processors := make([]*processor.Processor, 0, len(configurations))
+	for _, ic := range configurations {
+		err = r.checkLimits(ic, id)
+		if err != nil {
+			return err
+		}
+
+		processors = append(processors, &processor.Processor{
+			Source:             localOriginalPath,
+			Destination:        r.Paths.LocalImagePath(r.Namespace, r.Hash, ic.Filename),
+			ImageConfiguration: ic,
+			ImageDetails:       id,
+			Backend:            r.ServerConfiguration.Processor,
+			Context:            r.Context,
+		})
+	}
+
+	return processor.CreateImages(processors)
+}
+
+// original downloads the original, and returns its local path and its image details
+func (r *Request) original() (string, *info.ImageProperties, error) {
+	localOriginalPath := r.Paths.LocalOriginalPath(r.Namespace, r.Hash)
+
+	// The original file will be downloaded only once, even when every dimension requests it
+	err := r.DownloadOriginal()
+	if err != nil {
+		return "", nil, err
+	}
+
+	i := &info.Info{
+		Path: localOriginalPath,
+	}
+	id, err := i.ImageDetails()
+	if err != nil {
+		return "", nil, err
+	}
+	id.FocalPoint = r.storedFocalPoint()
+	return localOriginalPath, id, nil
+}
+
+// checkLimits ensures the original and the output are within the limits of the server
+func (r *Request) checkLimits(ic *core.ImageConfiguration, id *info.ImageProperties) error {
+	sc := r.ServerConfiguration
+	err := sc.CheckSourceDimensions(id.Width, id.Height)
+	if err != nil {
+		return err
+	}
+
+	if ic.Region != nil && !ic.Region.Within(id.Width, id.Height) {
+		r := ic.Region
+		return core.NewPolicyError("region %dx%d+%d+%d exceeds the original %dx%d", r.Width, r.Height, r.X, r.Y, id.Width, id.Height)
+	}
+
+	// The height of outputs by width depends on the aspect ratio of the original
+	width, height := ic.SourceDimensions(id.Width, id.Height)
+	if ic.Height == 0 && ic.Width > 0 && width > 0 {
+		return sc.CheckDimensions(ic.Width, ic.Width*height/width)
+	}
+	return nil
+}
+
 func (r *Request) uploadResizedImage(localResizedPath string, ic *core.ImageConfiguration) (err error) {
 	remoteResizedPath := r.Paths.RemoteImagePath(ic.Namespace, ic.ID, ic.Filename)
 	err = r.Uploader.Upload(localResizedPath, remoteResizedPath, ic.ToContentType())
@@ -1,6 +1,7 @@
 package request
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -19,7 +20,9 @@ type Request struct {
 	SourceURL           string
 	SourceData          io.ReadCloser
 	ContentType         string
-	directoryListing    map[string]string
+	// Context of the HTTP request, processing stops once it is done and no other request waits for the outputs
+	Context          context.Context
+	directoryListing map[string]string
 }
 
 func (r *Request) ProcessMultiple() error {
@@ -39,23 +42,22 @@ func (r *Request) ProcessMultiple() error {
 		return nil
 	}
 
-	// Process all the outputs
+	// Every output is validated before processing starts, so a policy
+	// violation does not leave some of the outputs generated
+	configurations, err := r.outputConfigurations(missing)
+	if err != nil {
+		return err
+	}
+
+	// Process all the outputs, the original is decoded once when the processor allows it
 	go func() {
 		defer close(errorProcessingChannel)
-		for _, filename := range missing {
-			ic, err := parser.NameToConfiguration(r.ServerConfiguration, filename)
-			if err != nil {
-				errorProcessingChannel <- err
-				return
-			}
-			ic.Namespace = r.Namespace
-			ic.ID = r.Hash
-
-			err = r.Process(ic)
-			if err != nil {
-				errorProcessingChannel <- err
-				return
-			}
+		err := r.processOutputs(configurations)
+		if err != nil {
+			errorProcessingChannel <- err
+			return
+		}
+		for _, ic := range configurations {
 			uploadQueue <- ic
 		}
 	}()
@@ -91,6 +93,21 @@ func (r *Request) ProcessMultiple() error {
 	return firstErr
 }
 
+// outputConfigurations parses every output, it fails on the first output that is not valid
+func (r *Request) outputConfigurations(outputs []string) ([]*core.ImageConfiguration, error) {
+	configurations := make([]*core.ImageConfiguration, 0, len(outputs))
+	for _, filename := range outputs {
+		ic, err := parser.NameToConfiguration(r.ServerConfiguration, r.Namespace, filename)
+		if err != nil {
+			return nil, err
+		}
+		ic.Namespace = r.Namespace
+		ic.ID = r.Hash
+		configurations = append(configurations, ic)
+	}
+	return configurations, nil
+}
+
 // CalculateMissingOutputs determine what versions need to be generated
 func (r *Request) CalculateMissingOutputs() (itemOutputs []string, err error) {
 	if r.Outputs == nil {
@@ -12,6 +12,7 @@ type FakeUploader struct{}
 func (u FakeUploader) CreateDirectory(string) error           { return nil }
 func (u FakeUploader) Upload(string, string, string) error    { return nil }
 func (u FakeUploader) ListDirectory(string) ([]string, error) { return []string{"a", "b"}, nil }
+func (u FakeUploader) Delete(string) error                    { return nil }
 func (u FakeUploader) Initialize() error                      { return nil }
 
 type FakePaths struct{}
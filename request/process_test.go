@@ -0,0 +1,49 @@
+package request_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/paths"
+	"github.com/image-server/image-server/request"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestOutputQualityOfStoredOutputs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "quality")
+	Ok(t, err)
+	defer os.RemoveAll(dir)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/p/f94/4de/077/34f1868a4355e1b86052704/w100-qauto.jpg":
+			http.ServeFile(w, req, "../test/images/a.jpg")
+		case "/p/f94/4de/077/34f1868a4355e1b86052704/w100-qauto.jpg.quality":
+			w.Write([]byte(`{"quality":72}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer ts.Close()
+
+	r := &request.Request{
+		ServerConfiguration: &core.ServerConfiguration{DefaultQuality: 90},
+		Uploader:            &FakeUploader{},
+		Paths:               &paths.Paths{LocalBasePath: dir, RemoteBaseURL: ts.URL},
+		Namespace:           "p",
+		Hash:                "f944de07734f1868a4355e1b86052704",
+	}
+	ic := &core.ImageConfiguration{ID: r.Hash, Namespace: "p", Filename: "w100-qauto.jpg", Width: 100, Format: "jpg", AutoQuality: true}
+	Ok(t, r.Process(ic))
+
+	quality, ok := r.OutputQuality(ic)
+	Equals(t, true, ok)
+	Equals(t, uint(72), quality)
+
+	_, ok = r.OutputQuality(&core.ImageConfiguration{ID: r.Hash, Namespace: "p", Filename: "w100.jpg", Width: 100, Format: "jpg", Quality: 90})
+	Equals(t, false, ok)
+}
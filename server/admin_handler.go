@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/processor/cli"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tylerb/graceful"
@@ -15,7 +16,11 @@ import (
 
 // AdminData keeps the current state of the server
 type AdminData struct {
-	Message string `json:"message"`
+	Message string          `json:"message"`
+	Formats map[string]bool `json:"formats,omitempty"`
+	// Processor is the name of the processor in use, Processors are the probed command line processors
+	Processor  string         `json:"processor,omitempty"`
+	Processors []*cli.Adapter `json:"processors,omitempty"`
 }
 
 // ShuttingDown variable is used to note that the server is about to shut down.
@@ -28,11 +33,11 @@ func init() {
 
 // InitializeAdminServer starts a web server that can be used to monitor the health of the application.
 // It returns a response with data code 200 if the system is healthy.
-func InitializeAdminServer(listen string, port string) {
+func InitializeAdminServer(sc *core.ServerConfiguration, listen string, port string) {
 	log.Printf("starting data check server on http://%s:%s", listen, port)
 
 	router := mux.NewRouter()
-	admin := &AdminHandler{}
+	admin := &AdminHandler{Processor: sc.Processor}
 	router.HandleFunc("/probe/ready", admin.ServeHTTP)
 	router.HandleFunc("/probe/live", admin.ServeHTTP)
 	router.Handle("/metrics", promhttp.Handler())
@@ -51,19 +56,21 @@ func InitializeAdminServer(listen string, port string) {
 	srv.ListenAndServe()
 }
 
-var data = &AdminData{}
-
 // AdminHandler implements the http.Handler interface
-type AdminHandler struct{}
+type AdminHandler struct {
+	// Processor is the processor backend, the Go backend doesn't need ImageMagick
+	Processor string
+}
 
 // ServeHTTP serves the http response for the health page.
 // It returns a response code 200 when the image server is available to process images.
 
 // It returns a data code 501 when the server is shutting down, or when a processor is not detected.
+// ImageMagick is not required by the Go processor backend.
 // Details are provided in the body of the request.
 //
 func (f *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	processorAvailable := cli.Available
+	processorAvailable := cli.Available || f.Processor == core.ProcessorGo
 
 	r := render.New(render.Options{
 		IndentJSON: true,
@@ -71,14 +78,21 @@ func (f *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	var code int
 
+	// every request reports the current state, concurrent requests don't share it
+	data := &AdminData{Processor: cli.Selected.Name, Processors: cli.Adapters}
+	if f.Processor == core.ProcessorGo {
+		data.Processor = core.ProcessorGo
+	}
+
 	if ShuttingDown {
 		data.Message = "Shutting down"
 		code = 501
 	} else if processorAvailable {
 		data.Message = "OK"
+		data.Formats = cli.OptionalFormatsSupport()
 		code = 200
 	} else {
-		data.Message = "There is no processor available. Make sure you have image magick installed."
+		data.Message = "There is no processor available. Make sure you have ImageMagick or GraphicsMagick installed."
 		code = 501
 	}
 
@@ -0,0 +1,34 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/server"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestAdminHandlerReportsTheCurrentState(t *testing.T) {
+	defer func() { server.ShuttingDown = false }()
+	admin := &server.AdminHandler{Processor: core.ProcessorGo}
+
+	request, _ := http.NewRequest("GET", "/probe/ready", nil)
+	response := httptest.NewRecorder()
+	admin.ServeHTTP(response, request)
+
+	Equals(t, http.StatusOK, response.Code)
+	Matches(t, `"formats"`, ReaderToString(response.Body))
+
+	// the formats of previous responses are not reported once the server is shutting down
+	server.ShuttingDown = true
+	response = httptest.NewRecorder()
+	admin.ServeHTTP(response, request)
+
+	Equals(t, http.StatusNotImplemented, response.Code)
+	body := ReaderToString(response.Body)
+	Matches(t, `"message": "Shutting down"`, body)
+	Assert(t, !strings.Contains(body, `"formats"`), "expected no formats, got %s", body)
+}
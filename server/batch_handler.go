@@ -50,7 +50,7 @@ func BatchHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerConfi
 
 	if err != nil {
 		log.Println(err)
-		errorHandler(err, w, req, 500)
+		errorHandlerJSON(err, w, http.StatusInternalServerError)
 		return
 	}
 
@@ -58,7 +58,7 @@ func BatchHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerConfi
 		result, err := getJobOutput(uuid, mantaClient)
 		if err != nil {
 			log.Println(err)
-			errorHandler(err, w, req, 500)
+			errorHandlerJSON(err, w, http.StatusInternalServerError)
 			return
 		}
 
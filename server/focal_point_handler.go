@@ -0,0 +1,48 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/logger"
+	"github.com/image-server/image-server/request"
+	"github.com/image-server/image-server/uploader"
+)
+
+// FocalPointHandler sets the focal point of an image with the x and y parameters (0.0 to 1.0).
+// Crops are centered on the focal point. Versions cropped around the previous focal point are removed
+// It returns the image details, including the new focal point
+func FocalPointHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerConfiguration) {
+	defer logger.RequestLatency("focal_point", time.Now())
+	qs := req.URL.Query()
+	vars := mux.Vars(req)
+
+	x, errX := strconv.ParseFloat(qs.Get("x"), 64)
+	y, errY := strconv.ParseFloat(qs.Get("y"), 64)
+	fp := &info.FocalPoint{X: x, Y: y}
+	if errX != nil || errY != nil || !fp.Valid() {
+		errorHandlerJSON(errors.New("x and y are required and must be between 0.0 and 1.0"), w, http.StatusBadRequest)
+		return
+	}
+
+	ir := request.Request{
+		ServerConfiguration: sc,
+		Namespace:           vars["namespace"],
+		Uploader:            uploader.DefaultUploader(sc),
+		Paths:               sc.Adapters.Paths,
+		Hash:                varsToHash(vars),
+	}
+
+	imageDetails, err := ir.UpdateFocalPoint(fp)
+	if err != nil {
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	renderImageDetails(w, imageDetails)
+}
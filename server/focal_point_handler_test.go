@@ -0,0 +1,55 @@
+package server_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/server"
+	. "github.com/image-server/image-server/test"
+)
+
+func TestFocalPointHandler(t *testing.T) {
+	sc := buildTestServerConfiguration()
+	router := server.NewRouter(sc)
+	dir := "../public/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd"
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(dir, 0700)
+	original, err := ioutil.ReadFile("../test/images/a.jpg")
+	Ok(t, err)
+	Ok(t, ioutil.WriteFile(dir+"/original", original, 0644))
+	Ok(t, ioutil.WriteFile(dir+"/x300.jpg", []byte{}, 0644))
+	Ok(t, ioutil.WriteFile(dir+"/w300.jpg", []byte{}, 0644))
+
+	request, _ := http.NewRequest("POST", "/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd/focal_point?x=0.25&y=0.75", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusOK, response.Code)
+	json := ReaderToString(response.Body)
+	Matches(t, "\"x\": 0.25", json)
+	Matches(t, "\"y\": 0.75", json)
+
+	details, err := info.LoadImageDetail(dir + "/info.json")
+	Ok(t, err)
+	Equals(t, &info.FocalPoint{X: 0.25, Y: 0.75}, details.FocalPoint)
+
+	_, err = os.Stat(dir + "/x300.jpg")
+	Assert(t, os.IsNotExist(err), "expected the cropped version to be removed")
+	ExpectFile(t, dir+"/w300.jpg")
+}
+
+func TestFocalPointHandlerWithInvalidPoint(t *testing.T) {
+	sc := buildTestServerConfiguration()
+	router := server.NewRouter(sc)
+
+	request, _ := http.NewRequest("POST", "/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd/focal_point?x=1.5&y=0.75", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusBadRequest, response.Code)
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+)
+
+// formatAuto is the virtual extension that picks the format from the Accept header
+const formatAuto = "auto"
+
+// Formats that can be picked by the Accept header, in order of preference
+var negotiatedFormats = []struct {
+	format      string
+	contentType string
+	animated    bool
+}{
+	{"avif", "image/avif", false},
+	{"webp", "image/webp", true},
+}
+
+// negotiateFormat returns the format of an "auto" output. The first format accepted by the client
+// and allowed in the namespace is used, otherwise GIF is used for animated originals, PNG for originals with transparency
+// and JPEG for the rest. Formats that can't keep the animation are skipped for animated originals
+func negotiateFormat(req *http.Request, sc *core.ServerConfiguration, namespace string, original *info.ImageProperties) string {
+	accept := req.Header.Get("Accept")
+
+	for _, f := range negotiatedFormats {
+		if original.Animated() && !f.animated {
+			continue
+		}
+		if acceptsContentType(accept, f.contentType) && !isFormatForbidden(f.format, namespace, sc) {
+			return f.format
+		}
+	}
+
+	if original.Animated() && !isFormatForbidden("gif", namespace, sc) {
+		return "gif"
+	}
+
+	if original.Transparent() && !isFormatForbidden("png", namespace, sc) {
+		return "png"
+	}
+	return "jpg"
+}
+
+// acceptsContentType returns true when the Accept header explicitly lists the content type.
+// Wildcards are ignored, since every client accepts JPEG and PNG
+func acceptsContentType(accept string, contentType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		if strings.TrimSpace(params[0]) != contentType {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) == 2 && kv[0] == "q" {
+				q, err := strconv.ParseFloat(kv[1], 64)
+				if err != nil || q <= 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/info"
+	"github.com/image-server/image-server/processor/cli"
+	. "github.com/image-server/image-server/test"
+)
+
+func requestWithAccept(accept string) *http.Request {
+	req, _ := http.NewRequest("GET", "/p/31e/8b3/187/a9f63f26d58c88bf09a7bbd/x300.auto", nil)
+	req.Header.Set("Accept", accept)
+	return req
+}
+
+var (
+	jpeg   = &info.ImageProperties{ContentType: "image/jpeg"}
+	png    = &info.ImageProperties{ContentType: "image/png", HasAlpha: info.Alpha(true)}
+	opaque = &info.ImageProperties{ContentType: "image/png", HasAlpha: info.Alpha(false)}
+	// stored before the transparency was detected
+	stale    = &info.ImageProperties{ContentType: "image/png"}
+	animated = &info.ImageProperties{ContentType: "image/gif", Frames: 12, Duration: 1200}
+)
+
+func withFormats(formats map[string]string) func() {
+	previous := cli.Formats
+	cli.Formats = formats
+	return func() { cli.Formats = previous }
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	defer withFormats(map[string]string{"avif": "rw+"})()
+	sc := &core.ServerConfiguration{}
+	chrome := "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+
+	Equals(t, "avif", negotiateFormat(requestWithAccept(chrome), sc, "p", jpeg))
+	Equals(t, "webp", negotiateFormat(requestWithAccept("image/webp,*/*"), sc, "p", jpeg))
+	Equals(t, "jpg", negotiateFormat(requestWithAccept("image/*,*/*;q=0.8"), sc, "p", jpeg))
+	Equals(t, "png", negotiateFormat(requestWithAccept("image/*,*/*;q=0.8"), sc, "p", png))
+	Equals(t, "jpg", negotiateFormat(requestWithAccept("image/*,*/*;q=0.8"), sc, "p", opaque))
+	Equals(t, "png", negotiateFormat(requestWithAccept("image/*,*/*;q=0.8"), sc, "p", stale))
+	Equals(t, "jpg", negotiateFormat(requestWithAccept("image/webp;q=0,*/*"), sc, "p", jpeg))
+}
+
+func TestNegotiateFormatWithoutAvifDelegate(t *testing.T) {
+	defer withFormats(map[string]string{"avif": "r--"})()
+	sc := &core.ServerConfiguration{}
+	chrome := "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+
+	Equals(t, "webp", negotiateFormat(requestWithAccept(chrome), sc, "p", jpeg))
+}
+
+func TestNegotiateFormatWithAllowedExtensions(t *testing.T) {
+	defer withFormats(map[string]string{"avif": "rw+"})()
+	sc := &core.ServerConfiguration{AllowedExtensions: []string{"jpg", "webp"}}
+	chrome := "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+
+	Equals(t, "webp", negotiateFormat(requestWithAccept(chrome), sc, "p", jpeg))
+	Equals(t, "jpg", negotiateFormat(requestWithAccept("*/*"), sc, "p", png))
+}
+
+func TestNegotiateFormatForAnimatedImages(t *testing.T) {
+	defer withFormats(map[string]string{"avif": "rw+"})()
+	sc := &core.ServerConfiguration{}
+	chrome := "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+
+	Equals(t, "webp", negotiateFormat(requestWithAccept(chrome), sc, "p", animated))
+	Equals(t, "gif", negotiateFormat(requestWithAccept("image/avif,*/*"), sc, "p", animated))
+}
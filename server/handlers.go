@@ -3,24 +3,63 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/image-server/image-server/core"
 
 	"github.com/unrolled/render"
 )
 
+// errorStatuses are the statuses of the responses to errors with a code
+var errorStatuses = map[string]int{
+	core.ErrorSourceMissing:     http.StatusNotFound,
+	core.ErrorSourceUnreachable: http.StatusBadGateway,
+	core.ErrorCorruptInput:      http.StatusUnprocessableEntity,
+	core.ErrorUnsupportedFormat: http.StatusUnsupportedMediaType,
+	core.ErrorPolicyViolation:   http.StatusBadRequest,
+	core.ErrorProcessorTimeout:  http.StatusGatewayTimeout,
+	core.ErrorStorageFailure:    http.StatusServiceUnavailable,
+	core.ErrorOverloaded:        http.StatusTooManyRequests,
+}
+
+// statusCodes are the codes of the responses to errors without a code
+var statusCodes = map[int]string{
+	http.StatusBadRequest: "bad_request",
+	http.StatusForbidden:  "forbidden",
+}
+
+// errorHandlerJSON responds with the error, its code and the error output of the processor:
+// {"error": "...", "code": "corrupt_input", "detail": "..."}
 func errorHandlerJSON(err error, w http.ResponseWriter, status int) {
 	r := render.New(render.Options{
 		IndentJSON: true,
 	})
 
+	code := core.ErrorCode(err)
+	if c, ok := statusCodes[status]; ok && code == core.ErrorInternal {
+		code = c
+	}
+
 	json := map[string]string{
 		"error": fmt.Sprintf("%s", err),
+		"code":  code,
+	}
+	if e, ok := err.(*core.Error); ok {
+		if e.Detail != "" {
+			json["detail"] = e.Detail
+		}
+		if e.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter/time.Second)))
+		}
 	}
 	r.JSON(w, status, json)
 }
 
-func errorHandler(err error, w http.ResponseWriter, r *http.Request, status int) {
-	w.WriteHeader(status)
-	if status == http.StatusNotFound {
-		fmt.Fprint(w, "404 image not available. ", err)
+// errorStatus returns the status of the code of the error, and the given status for errors without a code
+func errorStatus(err error, status int) int {
+	if s, ok := errorStatuses[core.ErrorCode(err)]; ok {
+		return s
 	}
+	return status
 }
@@ -40,7 +40,7 @@ func NewFileHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerCon
 	err := request.UploadFile(filename)
 	if err != nil {
 		glog.Error("Failed to upload file from ", sourceURL, " - ", err)
-		errorHandlerJSON(err, w, http.StatusNotFound)
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
@@ -10,6 +10,7 @@ import (
 	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/info"
 	"github.com/image-server/image-server/logger"
+	"github.com/image-server/image-server/parser"
 	"github.com/image-server/image-server/request"
 	"github.com/image-server/image-server/uploader"
 	"github.com/unrolled/render"
@@ -29,6 +30,15 @@ func NewImageHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerCo
 
 	if qs.Get("outputs") != "" {
 		outputs = strings.Split(qs.Get("outputs"), ",")
+	} else {
+		outputs = sc.OutputsFor(namespace)
+	}
+
+	err := validateOutputs(sc, namespace, outputs)
+	if err != nil {
+		go logger.ImagePostingFailed()
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusBadRequest))
+		return
 	}
 
 	request := &request.Request{
@@ -40,19 +50,34 @@ func NewImageHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerCo
 		SourceURL:           sourceURL,
 		SourceData:          req.Body,
 		ContentType:         contentType,
+		Context:             req.Context(),
 	}
 
 	imageDetails, err := request.Create()
 	if err != nil {
 		go logger.ImagePostingFailed()
 		glog.Error("Failed to create image from ", sourceURL, " - ", err)
-		errorHandlerJSON(err, w, http.StatusNotFound)
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
 	renderImageDetails(w, imageDetails)
 }
 
+// validateOutputs ensures every output is allowed in the namespace before the source is fetched
+func validateOutputs(sc *core.ServerConfiguration, namespace string, outputs []string) error {
+	for _, output := range outputs {
+		ic, err := parser.NameToConfiguration(sc, namespace, output)
+		if err != nil {
+			return err
+		}
+		if err := formatError(ic.Format, namespace, sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func renderImageDetails(w http.ResponseWriter, imageDetails *info.ImageProperties) {
 	r := render.New(render.Options{
 		IndentJSON: true,
@@ -212,3 +212,35 @@ func newfileUploadRequest(uri string, params map[string]string, paramName, path
 
 	return http.NewRequest("POST", uri, body)
 }
+
+func TestNewImageHandlerWithSourceTooLarge(t *testing.T) {
+	sc := buildTestServerConfiguration()
+	sc.MaximumSourcePixels = 1000
+	router := server.NewRouter(sc)
+
+	request, err := newUploadRequest("/test_namespace", "../test/images/a.jpg")
+	Ok(t, err)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusBadRequest, response.Code)
+	Matches(t, "exceeds the maximum of 1000 pixels", ReaderToString(response.Body))
+	_, err = os.Stat("../public/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd/original")
+	Assert(t, os.IsNotExist(err), "expected the original not to be stored")
+}
+
+func TestNewImageHandlerWithOutputTooLarge(t *testing.T) {
+	sc := buildTestServerConfiguration()
+	sc.MaximumWidth = 1000
+	router := server.NewRouter(sc)
+
+	request, err := newUploadRequest("/test_namespace?outputs=x300.jpg,99999x99999.jpg", "../test/images/a.jpg")
+	Ok(t, err)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusBadRequest, response.Code)
+	body := ReaderToString(response.Body)
+	Matches(t, "exceeds the maximum width of 1000", body)
+	Matches(t, `"code": "policy_violation"`, body)
+}
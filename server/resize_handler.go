@@ -1,9 +1,10 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,7 +12,9 @@ import (
 	"github.com/image-server/image-server/core"
 	"github.com/image-server/image-server/logger"
 	"github.com/image-server/image-server/parser"
+	"github.com/image-server/image-server/processor/cli"
 	"github.com/image-server/image-server/request"
+	"github.com/image-server/image-server/signature"
 	"github.com/image-server/image-server/uploader"
 )
 
@@ -24,56 +27,112 @@ func ResizeHandler(w http.ResponseWriter, req *http.Request, sc *core.ServerConf
 
 	vars := mux.Vars(req)
 	filename := vars["filename"]
+	namespace := vars["namespace"]
 
-	ic, err := parser.NameToConfiguration(sc, filename)
-	if err != nil {
-		errorHandler(err, w, req, http.StatusNotFound)
-		return
-	}
+	hash := varsToHash(vars)
 
-	if isFormatForbidden(ic.Format, sc) {
-		errorHandler(errors.New("Not Found"), w, req, http.StatusNotFound)
+	err := verifySignature(req, sc, namespace, hash, filename)
+	if err != nil {
+		errorHandlerJSON(err, w, http.StatusForbidden)
 		return
 	}
 
-	ic.ID = varsToHash(vars)
-	ic.Namespace = vars["namespace"]
-
 	qs := req.URL.Query()
 
 	ir := request.Request{
 		ServerConfiguration: sc,
-		Namespace:           vars["namespace"],
+		Namespace:           namespace,
 		Outputs:             strings.Split(qs.Get("outputs"), ","),
 		Uploader:            uploader.DefaultUploader(sc),
 		Paths:               sc.Adapters.Paths,
-		Hash:                ic.ID,
+		Hash:                hash,
+		Context:             req.Context(),
+	}
+
+	if filepath.Ext(filename) == "."+formatAuto {
+		// The format depends on the Accept header, every format is cached under its own filename
+		w.Header().Set("Vary", "Accept")
+
+		imageDetails, err := ir.ImageDetails()
+		if err != nil {
+			errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
+			return
+		}
+		filename = strings.TrimSuffix(filename, formatAuto) + negotiateFormat(req, sc, namespace, imageDetails)
+	}
+
+	ic, err := parser.NameToConfiguration(sc, namespace, filename)
+	if err != nil {
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	if err := formatError(ic.Format, namespace, sc); err != nil {
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
+		return
 	}
 
+	ic.ID = hash
+
 	err = ir.Process(ic)
 	if err != nil {
-		errorHandlerJSON(err, w, http.StatusNotFound)
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 
+	if quality, ok := ir.OutputQuality(ic); ok {
+		w.Header().Set("X-Image-Quality", strconv.Itoa(int(quality)))
+	}
+
 	localResizedPath := sc.Adapters.Paths.LocalImagePath(ic.Namespace, ic.ID, ic.Filename)
 	http.ServeFile(w, req, localResizedPath)
 }
 
+// verifySignature ensures the request is signed when the namespace requires signed URLs
+func verifySignature(req *http.Request, sc *core.ServerConfiguration, namespace string, hash string, filename string) error {
+	nc := sc.Namespace(namespace)
+	if !nc.RequiresSignature() {
+		return nil
+	}
+
+	qs := req.URL.Query()
+	var expires int64
+	if qs.Get("expires") != "" {
+		var err error
+		expires, err = strconv.ParseInt(qs.Get("expires"), 10, 64)
+		if err != nil {
+			return signature.ErrInvalidSignature
+		}
+	}
+
+	return signature.Verify(nc.SigningKeys, namespace, hash, filename, qs.Get("sig"), expires)
+}
+
 func varsToHash(vars map[string]string) string {
 	return fmt.Sprintf("%s%s%s%s", vars["id1"], vars["id2"], vars["id3"], vars["id4"])
 }
 
-func isFormatForbidden(format string, sc *core.ServerConfiguration) bool {
-	if format == "" || len(sc.AllowedExtensions) == 0 {
-		return false
+func isFormatForbidden(format string, namespace string, sc *core.ServerConfiguration) bool {
+	return formatError(format, namespace, sc) != nil
+}
+
+// formatError returns an unsupported format error when the processor can't write the format,
+// and a policy error when the namespace doesn't allow it
+func formatError(format string, namespace string, sc *core.ServerConfiguration) error {
+	if !cli.SupportsFormat(format) {
+		return core.NewError(core.ErrorUnsupportedFormat, "format %s is not supported by the processor", format)
+	}
+
+	allowedExtensions := sc.AllowedExtensionsFor(namespace)
+	if format == "" || len(allowedExtensions) == 0 {
+		return nil
 	}
 
-	for _, ext := range sc.AllowedExtensions {
+	for _, ext := range allowedExtensions {
 		if ext == format {
-			return false
+			return nil
 		}
 	}
 
-	return true
+	return core.NewPolicyError("format %s is not allowed in namespace %s", format, namespace)
 }
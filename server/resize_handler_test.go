@@ -0,0 +1,145 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/image-server/image-server/core"
+	"github.com/image-server/image-server/paths"
+	"github.com/image-server/image-server/server"
+	. "github.com/image-server/image-server/test"
+)
+
+func buildTestSignedServerConfiguration() *core.ServerConfiguration {
+	sc := buildTestServerConfiguration()
+	sc.Namespaces = map[string]*core.NamespaceConfiguration{
+		"signed": {SigningKeys: []string{"new-secret", "old-secret"}},
+	}
+	return sc
+}
+
+func TestResizeHandlerWithoutSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+
+	request, _ := http.NewRequest("GET", "/signed/31e/8b3/187/a9f63f26d58c88bf09a7bbd/x300.jpg", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusForbidden, response.Code)
+	Matches(t, "signature is required", ReaderToString(response.Body))
+}
+
+func TestResizeHandlerWithInvalidSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+	p := &paths.Paths{}
+	url, err := p.SignedImageURL("", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", "x300.jpg", "unknown-secret", time.Time{})
+	Ok(t, err)
+
+	request, _ := http.NewRequest("GET", url, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusForbidden, response.Code)
+}
+
+func TestResizeHandlerWithExpiredSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+	p := &paths.Paths{}
+	url, err := p.SignedImageURL("", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", "x300.jpg", "old-secret", time.Now().Add(-time.Minute))
+	Ok(t, err)
+
+	request, _ := http.NewRequest("GET", url, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusForbidden, response.Code)
+	Matches(t, "signature has expired", ReaderToString(response.Body))
+}
+
+func TestResizeHandlerWithValidSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+	p := &paths.Paths{}
+	url, err := p.SignedImageURL("http://localhost:7000", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", "x300.jpg", "old-secret", time.Now().Add(time.Minute))
+	Ok(t, err)
+	Matches(t, "^http://localhost:7000/signed/31e/8b3/187/a9f63f26d58c88bf09a7bbd/x300.jpg\\?expires=[0-9]+&sig=", url)
+
+	request, _ := http.NewRequest("GET", url, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	// The original image is not available
+	Equals(t, http.StatusNotFound, response.Code)
+	Matches(t, `"code": "source_missing"`, ReaderToString(response.Body))
+}
+
+func TestResizeHandlerWithAutoFormat(t *testing.T) {
+	router := server.NewRouter(buildTestServerConfiguration())
+
+	request, _ := http.NewRequest("GET", "/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd/x300.auto", nil)
+	request.Header.Set("Accept", "image/webp,*/*")
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	// The original image is not available
+	Equals(t, http.StatusNotFound, response.Code)
+	Equals(t, "Accept", response.Header().Get("Vary"))
+}
+
+func TestResizeHandlerWithInvalidOptions(t *testing.T) {
+	router := server.NewRouter(buildTestServerConfiguration())
+
+	request, _ := http.NewRequest("GET", "/test_namespace/31e/8b3/187/a9f63f26d58c88bf09a7bbd/300x200-blur99.jpg", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusBadRequest, response.Code)
+	Matches(t, `"code": "policy_violation"`, ReaderToString(response.Body))
+}
+
+func TestSignedImageURLWithInvalidServerURL(t *testing.T) {
+	p := &paths.Paths{}
+	_, err := p.SignedImageURL("http://localhost:7000/%zz", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", "x300.jpg", "new-secret", time.Time{})
+	Assert(t, err != nil, "expected an invalid server URL to be an error")
+}
+
+func TestResizeManyHandlerWithoutSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+
+	request, _ := http.NewRequest("POST", "/signed/31e/8b3/187/a9f63f26d58c88bf09a7bbd/process?outputs=x300.jpg,x600.jpg", nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusForbidden, response.Code)
+	Matches(t, "signature is required", ReaderToString(response.Body))
+}
+
+func TestResizeManyHandlerWithSignatureOfOtherOutputs(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+	p := &paths.Paths{}
+	url, err := p.SignedProcessURL("", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", []string{"x300.jpg"}, "new-secret", time.Time{})
+	Ok(t, err)
+
+	request, _ := http.NewRequest("POST", strings.Replace(url, "x300.jpg", "x300.jpg%2Cx9000.jpg", 1), nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	Equals(t, http.StatusForbidden, response.Code)
+}
+
+func TestResizeManyHandlerWithValidSignature(t *testing.T) {
+	router := server.NewRouter(buildTestSignedServerConfiguration())
+	p := &paths.Paths{}
+	url, err := p.SignedProcessURL("http://localhost:7000", "signed", "31e8b3187a9f63f26d58c88bf09a7bbd", []string{"x300.jpg", "x600.jpg"}, "new-secret", time.Now().Add(time.Minute))
+	Ok(t, err)
+	Matches(t, "^http://localhost:7000/signed/31e/8b3/187/a9f63f26d58c88bf09a7bbd/process\\?expires=[0-9]+&outputs=x300.jpg%2Cx600.jpg&sig=", url)
+
+	request, _ := http.NewRequest("POST", url, nil)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+
+	// The original image is not available
+	Equals(t, http.StatusNotFound, response.Code)
+}
@@ -22,6 +22,14 @@ func ResizeManyHandler(w http.ResponseWriter, req *http.Request, sc *core.Server
 
 	qs := req.URL.Query()
 	vars := mux.Vars(req)
+	hash := varsToHash(vars)
+
+	// the list of outputs is signed like the filename of a resize URL
+	err := verifySignature(req, sc, vars["namespace"], hash, qs.Get("outputs"))
+	if err != nil {
+		errorHandlerJSON(err, w, http.StatusForbidden)
+		return
+	}
 
 	ir := request.Request{
 		ServerConfiguration: sc,
@@ -29,12 +37,13 @@ func ResizeManyHandler(w http.ResponseWriter, req *http.Request, sc *core.Server
 		Outputs:             strings.Split(qs.Get("outputs"), ","),
 		Uploader:            uploader.DefaultUploader(sc),
 		Paths:               sc.Adapters.Paths,
-		Hash:                varsToHash(vars),
+		Hash:                hash,
+		Context:             req.Context(),
 	}
 
-	err := ir.ProcessMultiple()
+	err = ir.ProcessMultiple()
 	if err != nil {
-		errorHandlerJSON(err, w, http.StatusInternalServerError)
+		errorHandlerJSON(err, w, errorStatus(err, http.StatusInternalServerError))
 		return
 	}
 	w.WriteHeader(200)
@@ -13,7 +13,7 @@ import (
 
 // InitializeServer creates a new http server to handle image processing requests
 func InitializeServer(sc *core.ServerConfiguration, listen string, port string) {
-	go InitializeAdminServer(listen, "7002")
+	go InitializeAdminServer(sc, listen, "7002")
 	log.Printf("starting server on http://%s:%s", listen, port)
 	router := NewRouter(sc)
 	n := negroni.Classic()
@@ -33,6 +33,10 @@ func NewRouter(sc *core.ServerConfiguration) *mux.Router {
 		ResizeManyHandler(wr, req, sc)
 	}).Methods("POST").Name("resizeMany")
 
+	router.HandleFunc("/{namespace:[a-z0-9_]+}/{id1:[a-f0-9]{3}}/{id2:[a-f0-9]{3}}/{id3:[a-f0-9]{3}}/{id4:[a-f0-9]{23}}/focal_point", func(wr http.ResponseWriter, req *http.Request) {
+		FocalPointHandler(wr, req, sc)
+	}).Methods("POST").Name("focalPoint")
+
 	router.HandleFunc("/{namespace:[a-z0-9_]+}/{id1:[a-f0-9]{3}}/{id2:[a-f0-9]{3}}/{id3:[a-f0-9]{3}}/{id4:[a-f0-9]{23}}/{filename}", func(wr http.ResponseWriter, req *http.Request) {
 		ResizeHandler(wr, req, sc)
 	}).Methods("GET").Name("resizeImage")
@@ -49,7 +53,7 @@ func NewRouter(sc *core.ServerConfiguration) *mux.Router {
 		BatchHandler(wr, req, sc)
 	}).Methods("GET").Name("batch")
 
-	admin := &AdminHandler{}
+	admin := &AdminHandler{Processor: sc.Processor}
 	router.HandleFunc("/status_check", admin.ServeHTTP)
 	return router
 }
@@ -0,0 +1,52 @@
+// Package signature signs and verifies the URLs of processed images.
+// The signature is an HMAC-SHA256 of the namespace, image hash, filename and expiry
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrMissingSignature is returned when a signature is required but not provided
+	ErrMissingSignature = errors.New("signature is required")
+	// ErrInvalidSignature is returned when the signature does not match any key
+	ErrInvalidSignature = errors.New("signature is not valid")
+	// ErrExpiredSignature is returned when the expiry timestamp is in the past
+	ErrExpiredSignature = errors.New("signature has expired")
+)
+
+// Sign returns the signature of an output. expires is a unix timestamp, 0 when the signature does not expire
+func Sign(key string, namespace string, hash string, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(message(namespace, hash, filename, expires)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns nil when the signature was created with any of the keys, and it has not expired.
+// Multiple keys allow rotating them without invalidating existing URLs
+func Verify(keys []string, namespace string, hash string, filename string, signature string, expires int64) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	if expires > 0 && time.Now().Unix() > expires {
+		return ErrExpiredSignature
+	}
+
+	for _, key := range keys {
+		expected := Sign(key, namespace, hash, filename, expires)
+		if hmac.Equal([]byte(expected), []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func message(namespace string, hash string, filename string, expires int64) string {
+	return fmt.Sprintf("%s/%s/%s:%d", namespace, hash, filename, expires)
+}
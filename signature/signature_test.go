@@ -0,0 +1,40 @@
+package signature_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/image-server/image-server/signature"
+	. "github.com/image-server/image-server/test"
+)
+
+const hash = "6e0072682e66287b662827da75b244a3"
+
+func TestVerify(t *testing.T) {
+	sig := signature.Sign("secret", "p", hash, "x300.jpg", 0)
+
+	Ok(t, signature.Verify([]string{"secret"}, "p", hash, "x300.jpg", sig, 0))
+	Equals(t, signature.ErrInvalidSignature, signature.Verify([]string{"secret"}, "p", hash, "x301.jpg", sig, 0))
+	Equals(t, signature.ErrInvalidSignature, signature.Verify([]string{"secret"}, "avatars", hash, "x300.jpg", sig, 0))
+	Equals(t, signature.ErrMissingSignature, signature.Verify([]string{"secret"}, "p", hash, "x300.jpg", "", 0))
+}
+
+func TestVerifyWithRotatedKeys(t *testing.T) {
+	sig := signature.Sign("old", "p", hash, "x300.jpg", 0)
+
+	Ok(t, signature.Verify([]string{"new", "old"}, "p", hash, "x300.jpg", sig, 0))
+	Equals(t, signature.ErrInvalidSignature, signature.Verify([]string{"new"}, "p", hash, "x300.jpg", sig, 0))
+}
+
+func TestVerifyWithExpiry(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	sig := signature.Sign("secret", "p", hash, "x300.jpg", future)
+	Ok(t, signature.Verify([]string{"secret"}, "p", hash, "x300.jpg", sig, future))
+
+	// the expiry is part of the signature
+	Equals(t, signature.ErrInvalidSignature, signature.Verify([]string{"secret"}, "p", hash, "x300.jpg", sig, future+60))
+
+	past := time.Now().Add(-time.Hour).Unix()
+	sig = signature.Sign("secret", "p", hash, "x300.jpg", past)
+	Equals(t, signature.ErrExpiredSignature, signature.Verify([]string{"secret"}, "p", hash, "x300.jpg", sig, past))
+}
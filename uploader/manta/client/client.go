@@ -130,6 +130,19 @@ func (c *Client) DeleteDirectory(path string) error {
 	return c.ensureStatus(resp, 204)
 }
 
+// DeleteObject deletes an object. On success an HTTP 204 is returned
+// https://apidocs.joyent.com/manta/api.html#DeleteObject
+func (c *Client) DeleteObject(path string) error {
+	resp, err := c.Delete(path)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return c.ensureStatus(resp, 204)
+}
+
 // ListDirectory lists the contents of a directory
 // https://apidocs.joyent.com/manta/api.html#ListDirectory
 func (c *Client) ListDirectory(path string) ([]Entry, error) {
@@ -13,6 +13,7 @@ import (
 type MantaClient interface {
 	PutObject(destination string, contentType string, object io.Reader) error
 	PutDirectory(path string) error
+	DeleteObject(path string) error
 }
 
 type Uploader struct {
@@ -92,6 +93,15 @@ func (u *Uploader) ListDirectory(directory string) ([]string, error) {
 	return names, nil
 }
 
+// Delete removes an object from manta
+func (u *Uploader) Delete(path string) error {
+	err := u.Client.DeleteObject(path)
+	if err == nil {
+		glog.Infof("Deleted object on manta: %s", path)
+	}
+	return err
+}
+
 func Initialize(baseDir string, url string, user string, keyID string, identityPath string) error {
 	u := DefaultUploader()
 	MantaURL = url
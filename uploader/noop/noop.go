@@ -12,6 +12,11 @@ func (u *Uploader) CreateDirectory(path string) error {
 	return nil
 }
 
+// Delete does nothing
+func (u *Uploader) Delete(path string) error {
+	return nil
+}
+
 // ListDirectory does nothing and returns empty array
 func (u *Uploader) ListDirectory(directory string) ([]string, error) {
 	var names []string
@@ -84,6 +84,15 @@ func (u *Uploader) ListDirectory(directory string) ([]string, error) {
 	return names, err
 }
 
+// Delete removes an object from the bucket
+func (u *Uploader) Delete(path string) error {
+	_, err := svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(path),
+	})
+	return err
+}
+
 // CreateDirectory does nothing since a directory does not need to be created on S3
 // Directories are virtual, and defined by the path of the object
 func (u *Uploader) CreateDirectory(path string) error {
@@ -2,6 +2,7 @@ package uploader
 
 import (
 	"errors"
+	"os"
 	"time"
 
 	"github.com/golang/glog"
@@ -34,6 +35,7 @@ func DefaultUploader(sc *core.ServerConfiguration) *Uploader {
 	return u
 }
 
+// Upload uploads a file, and the quality selected for it when the file is an output without a fixed quality
 func (u *Uploader) Upload(source string, destination string, contType string) error {
 	start := time.Now()
 
@@ -44,11 +46,28 @@ func (u *Uploader) Upload(source string, destination string, contType string) er
 	err := u.Uploader.Upload(source, destination, contType)
 	elapsed := time.Since(start)
 	glog.Infof("Took %s to upload image: %s", elapsed, destination)
-	return err
+	if err != nil {
+		return storageError(err)
+	}
+
+	if _, err := os.Stat(core.QualityFile(source)); err == nil {
+		err = u.Uploader.Upload(core.QualityFile(source), core.QualityFile(destination), "text/plain")
+	}
+	return storageError(err)
 }
 
 func (u *Uploader) ListDirectory(directory string) ([]string, error) {
-	return u.Uploader.ListDirectory(directory)
+	files, err := u.Uploader.ListDirectory(directory)
+	return files, storageError(err)
+}
+
+// Delete removes a file from the remote store
+func (u *Uploader) Delete(path string) error {
+	err := u.Uploader.Delete(path)
+	if err != nil {
+		glog.Errorf("Unable to delete remote file %s: %s", path, err)
+	}
+	return storageError(err)
 }
 
 func (u *Uploader) CreateDirectory(path string) error {
@@ -56,7 +75,15 @@ func (u *Uploader) CreateDirectory(path string) error {
 	directoryPath := u.Uploader.CreateDirectory(path)
 	elapsed := time.Since(start)
 	glog.Infof("Took %s to generate remote directory: %s", elapsed, path)
-	return directoryPath
+	return storageError(directoryPath)
+}
+
+// storageError returns the error of the remote store as a storage failure
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return core.NewError(core.ErrorStorageFailure, "%s", err)
 }
 
 func Initialize(sc *core.ServerConfiguration) error {